@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchLoader loads the values of keys which are missing in cache.
+type BatchLoader interface {
+	// LoadMany Retrieves the values of keys from the origin, such as database.
+	// Keys absent in the origin are left out of the map returned.
+	LoadMany(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+}
+
+// BatchLoaderFunc is an adapter to allow the use of ordinary functions as BatchLoader.
+type BatchLoaderFunc func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+
+// LoadMany calls f(ctx, keys).
+func (f BatchLoaderFunc) LoadMany(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	return f(ctx, keys)
+}
+
+type BatchConfig struct {
+	// Wait is the time window to collect Gets into one batch.
+	Wait time.Duration
+	// MaxBatch dispatches the batch at once when it holds so many keys, no limit if equal 0.
+	MaxBatch int
+	// Loader loads the keys missing in cache if not nil.
+	Loader BatchLoader
+	// TTL is the ttl of values stored by Loader.
+	TTL time.Duration
+	// LoadTimeout bounds the MGet, LoadMany and MSet of a batch, no limit if equal 0.
+	LoadTimeout time.Duration
+}
+
+var DefaultBatchConfig = BatchConfig{
+	Wait:     time.Millisecond,
+	MaxBatch: 100,
+}
+
+var _ Cache = (*batchCache)(nil)
+
+type batchCache struct {
+	Cache
+	BatchConfig
+	mu sync.Mutex
+	b  *batch
+}
+
+type batch struct {
+	keys  []interface{}
+	index map[interface{}]struct{}
+	timer *time.Timer
+	done  chan struct{}
+	ret   map[interface{}]interface{}
+	errs  map[interface{}]error // errors of single keys
+	err   error                 // error of the whole batch
+}
+
+// NewBatchCache wraps c into a cache whose Gets arriving within cfg.Wait are coalesced into one MGet.
+func NewBatchCache(c Cache, cfg BatchConfig) *batchCache {
+	return &batchCache{
+		Cache:       c,
+		BatchConfig: cfg,
+	}
+}
+
+// Close closes the underlying cache if it's a cache.Closer, Gets of the pending batch fail with its error then.
+func (c *batchCache) Close(ctx context.Context) error {
+	return closeCache(ctx, c.Cache)
+}
+
+// Get Retrieves a value from cache with a specified key.
+// The key is retrieved by the MGet of current batch, options of the batch are not affected by Get,
+// cache.WithContext() only bounds the time waiting for the batch.
+func (c *batchCache) Get(key interface{}, options ...Option) (interface{}, error) {
+	var o Options
+	o.Apply(options...)
+
+	b := c.add(key)
+	select {
+	case <-b.done:
+	case <-o.Ctx.Done():
+		return nil, o.Ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err, ok := b.errs[key]; ok {
+		return nil, err
+	}
+	v, ok := b.ret[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (c *batchCache) add(key interface{}) *batch {
+	c.mu.Lock()
+	b := c.b
+	if b == nil {
+		b = &batch{
+			index: make(map[interface{}]struct{}),
+			done:  make(chan struct{}),
+		}
+		b.timer = time.AfterFunc(c.Wait, func() {
+			c.mu.Lock()
+			if c.b != b { // dispatched already
+				c.mu.Unlock()
+				return
+			}
+			c.b = nil
+			c.mu.Unlock()
+			c.dispatch(b)
+		})
+		c.b = b
+	}
+	if _, ok := b.index[key]; !ok {
+		b.index[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+	full := c.MaxBatch > 0 && len(b.keys) >= c.MaxBatch
+	if full {
+		c.b = nil
+	}
+	c.mu.Unlock()
+
+	if full {
+		b.timer.Stop()
+		go c.dispatch(b)
+	}
+	return b
+}
+
+// dispatch retrieves the keys of b, a failed MGet fails the whole batch,
+// while errors of loading or decoding only fail the keys missing in cache.
+func (c *batchCache) dispatch(b *batch) {
+	defer close(b.done)
+
+	// the batch serves many callers, so it doesn't share any caller's context
+	ctx := context.Background()
+	if c.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.LoadTimeout)
+		defer cancel()
+	}
+
+	ret, err := c.Cache.MGet(b.keys, WithContext(ctx))
+	if err != nil {
+		b.err = err
+		return
+	}
+	if ret == nil {
+		ret = make(map[interface{}]interface{})
+	}
+	b.ret = ret
+	if c.Loader == nil || len(ret) == len(b.keys) {
+		return
+	}
+
+	misses := make([]interface{}, 0, len(b.keys)-len(ret))
+	for _, key := range b.keys {
+		if _, ok := ret[key]; !ok {
+			misses = append(misses, key)
+		}
+	}
+	loaded, err := c.Loader.LoadMany(ctx, misses)
+	if err != nil {
+		b.errs = make(map[interface{}]error, len(misses))
+		for _, key := range misses {
+			b.errs[key] = err
+		}
+		return
+	}
+	if len(loaded) == 0 {
+		return
+	}
+	_ = c.Cache.MSet(loaded, WithContext(ctx), WithTTL(c.TTL))
+	for k, v := range loaded {
+		sv, err := stored(c.Cache, v)
+		if err != nil {
+			if b.errs == nil {
+				b.errs = make(map[interface{}]error)
+			}
+			b.errs[k] = err
+			continue
+		}
+		ret[k] = sv
+	}
+}
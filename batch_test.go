@@ -0,0 +1,141 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/local"
+	"github.com/stretchr/testify/assert"
+)
+
+type mgetCounter struct {
+	cache.Cache
+	calls int32
+}
+
+func (c *mgetCounter) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.Cache.MGet(keys, options...)
+}
+
+func Test_BatchCacheGet(t *testing.T) {
+	counter := &mgetCounter{Cache: local.NewLocalCache()}
+	c := cache.NewBatchCache(counter, cache.BatchConfig{
+		Wait:     time.Second,
+		MaxBatch: 10,
+	})
+
+	n := 100
+	for i := 0; i < n/2; i++ {
+		c.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.Get(i)
+			if i < n/2 {
+				assert.NoError(t, err)
+				assert.Equal(t, i, v)
+			} else {
+				assert.Equal(t, cache.ErrNotFound, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(n/10), atomic.LoadInt32(&counter.calls))
+}
+
+func Test_BatchCacheLoader(t *testing.T) {
+	var loads int32
+	c := cache.NewBatchCache(local.NewLocalCache(), cache.BatchConfig{
+		Wait: 50 * time.Millisecond,
+		Loader: cache.BatchLoaderFunc(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			ret := make(map[interface{}]interface{})
+			for _, key := range keys {
+				if key.(int)%2 == 0 {
+					ret[key] = key
+				}
+			}
+			return ret, nil
+		}),
+	})
+
+	n := 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.Get(i)
+			if i%2 == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, i, v)
+			} else {
+				assert.Equal(t, cache.ErrNotFound, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// loaded values are stored
+	for i := 0; i < n; i++ {
+		ok, err := c.Exists(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i%2 == 0, ok)
+	}
+}
+
+func Test_BatchCacheGetContext(t *testing.T) {
+	c := cache.NewBatchCache(local.NewLocalCache(), cache.BatchConfig{
+		Wait: time.Second,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(1, cache.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func Test_BatchCacheLoaderError(t *testing.T) {
+	errOrigin := errors.New("origin is down")
+	c := cache.NewBatchCache(local.NewLocalCache(), cache.BatchConfig{
+		Wait:        50 * time.Millisecond,
+		LoadTimeout: time.Second,
+		Loader: cache.BatchLoaderFunc(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			return nil, errOrigin
+		}),
+	})
+
+	n := 10
+	for i := 0; i < n/2; i++ {
+		c.Set(i, i)
+	}
+
+	// hits of the batch are not failed by the loader
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.Get(i)
+			if i < n/2 {
+				assert.NoError(t, err)
+				assert.Equal(t, i, v)
+			} else {
+				assert.Equal(t, errOrigin, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules work, caches accepting a Clock can be tested with a FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks on C() periodically until Stop.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is returned by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the timer from firing, false is returned if it fired or stopped already.
+	Stop() bool
+}
+
+// RealClock is the Clock of package time.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// FakeClock is a Clock which only moves by Advance and Set.
+// Tickers and timers due are fired by them, like those of package time, ticks are dropped
+// if the receiver falls behind, and functions of AfterFunc are called in their own goroutines.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters map[*fakeWaiter]struct{}
+}
+
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration // 0 for timers
+	c      chan time.Time
+	f      func()
+}
+
+// NewFakeClock creates a FakeClock at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:     now,
+		waiters: make(map[*fakeWaiter]struct{}),
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.waiters[w] = struct{}{}
+	return fakeTicker{w}
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), f: f}
+	c.waiters[w] = struct{}{}
+	c.mu.Unlock()
+
+	c.Advance(0) // fire at once if d <= 0
+	return w
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to t, which should not be before Now().
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.set(t)
+}
+
+// set fires waiters due at t, called with c.mu held and releases it.
+func (c *FakeClock) set(t time.Time) {
+	c.now = t
+	var fs []func()
+	for w := range c.waiters {
+		if w.at.After(t) {
+			continue
+		}
+		if w.period > 0 {
+			select {
+			case w.c <- w.at:
+			default:
+			}
+			for !w.at.After(t) {
+				w.at = w.at.Add(w.period)
+			}
+			continue
+		}
+		delete(c.waiters, w)
+		fs = append(fs, w.f)
+	}
+	c.mu.Unlock()
+
+	for _, f := range fs {
+		go f()
+	}
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	_, ok := w.clock.waiters[w]
+	delete(w.clock.waiters, w)
+	return ok
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	assert.Equal(t, start, c.Now())
+
+	tick := c.NewTicker(time.Second)
+	fired := make(chan struct{}, 10)
+	c.AfterFunc(time.Minute, func() { fired <- struct{}{} })
+	stopped := c.AfterFunc(time.Minute, func() { fired <- struct{}{} })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-tick.C():
+		t.Fatal("ticked too early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-tick.C())
+
+	// ticks are dropped if not received
+	c.Advance(10 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-tick.C())
+	select {
+	case <-tick.C():
+		t.Fatal("ticks should be dropped")
+	default:
+	}
+	c.Advance(time.Second)
+	assert.Equal(t, start.Add(12*time.Second), <-tick.C())
+
+	tick.Stop()
+	c.Set(start.Add(time.Minute))
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer not fired")
+	}
+	select {
+	case <-tick.C():
+		t.Fatal("ticker stopped")
+	case <-fired:
+		t.Fatal("timer stopped")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+}
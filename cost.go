@@ -0,0 +1,25 @@
+package cache
+
+// Sizer is implemented by values which know their size, such as the size in bytes.
+type Sizer interface {
+	Size() int64
+}
+
+// CostFunc returns the cost of an entry, which is counted against the max cost of cache.
+type CostFunc func(key, value interface{}) int64
+
+// DefaultCostFunc returns a CostFunc which takes Size() as the cost if the value is a Sizer,
+// otherwise the encoded size if encoder is not nil, otherwise 1.
+func DefaultCostFunc(encoder Encoder) CostFunc {
+	return func(key, value interface{}) int64 {
+		if s, ok := value.(Sizer); ok {
+			return s.Size()
+		}
+		if encoder != nil {
+			if b, err := encoder.Encode(value); err == nil {
+				return int64(len(b))
+			}
+		}
+		return 1
+	}
+}
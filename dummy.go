@@ -1,6 +1,9 @@
 package cache
 
+import "context"
+
 var _ Cache = (*dummyCache)(nil)
+var _ Closer = (*dummyCache)(nil)
 
 type dummyCache struct{}
 
@@ -35,3 +38,8 @@ func (c *dummyCache) Clear(options ...Option) error {
 func (c *dummyCache) Codec() Codec {
 	return nil
 }
+
+// Close does nothing, dummy cache holds nothing.
+func (c *dummyCache) Close(ctx context.Context) error {
+	return nil
+}
@@ -6,9 +6,12 @@ import (
 )
 
 var (
-	ErrNotFound    = NewCacheError(errors.New("not found"))
-	ErrExisted     = NewCacheError(errors.New("not existed"))
-	ErrUnsupported = NewCacheError(errors.New("unsupported"))
+	ErrNotFound        = NewCacheError(errors.New("not found"))
+	ErrExisted         = NewCacheError(errors.New("existed"))
+	ErrUnsupported     = NewCacheError(errors.New("unsupported"))
+	ErrTypeMismatch    = NewCacheError(errors.New("type mismatch"))
+	ErrClosed          = NewCacheError(errors.New("closed"))
+	ErrVersionMismatch = NewCacheError(errors.New("version mismatch"))
 )
 
 type tagError struct {
@@ -0,0 +1,31 @@
+package cache
+
+// EvictReason tells why an entry left a cache.
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota + 1 // evicted to make room within the capacity
+	EvictExpired                         // expired
+	EvictDeleted                         // deleted by Delete, or by Expire with a non-positive ttl
+	EvictCleared                         // removed by Clear
+	EvictReplaced                        // the value replaced by a new one
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictCleared:
+		return "cleared"
+	case EvictReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// EvictFunc is called with an entry which left a cache and the reason.
+type EvictFunc func(key, value interface{}, reason EvictReason)
@@ -0,0 +1,13 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EvictReasonString(t *testing.T) {
+	assert.Equal(t, "capacity", EvictCapacity.String())
+	assert.Equal(t, "replaced", EvictReplaced.String())
+	assert.Equal(t, "unknown", EvictReason(0).String())
+}
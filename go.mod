@@ -1,8 +1,15 @@
 module github.com/ryanking8215/go-cache
 
-go 1.13
+go 1.20
 
 require (
 	github.com/go-redis/redis/v7 v7.0.0-beta.5
-	github.com/stretchr/testify v1.4.0
+	github.com/stretchr/testify v1.8.0
+	go.uber.org/goleak v1.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
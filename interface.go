@@ -1,5 +1,10 @@
 package cache
 
+import (
+	"context"
+	"time"
+)
+
 // Cache cache interface
 type Cache interface {
 	// Get Retrieves a value from cache with a specified key.
@@ -36,6 +41,13 @@ type Cache interface {
 	Codec() Codec
 }
 
+// StaleGetter is implemented by caches which keep entries past their soft expiry,
+// see cache.WithStaleTTL() and cache.WithRefreshAhead().
+type StaleGetter interface {
+	// GetStale is like Get, but also reports whether the entry is past its soft expiry and should be refreshed.
+	GetStale(key interface{}, options ...Option) (value interface{}, stale bool, err error)
+}
+
 // Encoder encoder interface
 type Encoder interface {
 	// Encode encode value to byte slices
@@ -55,3 +67,96 @@ type Codec interface {
 	Encoder
 	Decoder
 }
+
+// Expirer is implemented by caches which can tell and change the expiry of keys.
+// ErrNotFound is returned for keys missing or expired.
+type Expirer interface {
+	// TTL returns the time left before key expires, 0 if it never expires.
+	TTL(key interface{}, options ...Option) (time.Duration, error)
+
+	// Expire makes key expire after ttl from now, or at once if ttl is not positive.
+	Expire(key interface{}, ttl time.Duration, options ...Option) error
+
+	// Persist makes key never expire.
+	Persist(key interface{}, options ...Option) error
+
+	// Touch renews the expiry of key with the ttl it was set with, as if it were set now.
+	// Soft expiry isn't renewed. cache.WithTTL() renews with another ttl instead, caches which
+	// don't keep the ttl, like redis caches, require it and return ErrUnsupported without it.
+	Touch(key interface{}, options ...Option) error
+}
+
+// Counter is implemented by caches which can change integer or float values atomically.
+// A missing or expired key is created with delta, cache.WithTTL() applies only to the key created,
+// the ttl of an existing key is kept. ErrTypeMismatch is returned if the value is not a number.
+type Counter interface {
+	// Incr increments the value of key by 1, and returns the new value.
+	Incr(key interface{}, options ...Option) (int64, error)
+
+	// Decr decrements the value of key by 1, and returns the new value.
+	Decr(key interface{}, options ...Option) (int64, error)
+
+	// IncrBy increments the value of key by delta, and returns the new value.
+	IncrBy(key interface{}, delta int64, options ...Option) (int64, error)
+
+	// IncrByFloat increments the value of key by float delta, and returns the new value.
+	IncrByFloat(key interface{}, delta float64, options ...Option) (float64, error)
+}
+
+// ConditionalSetter is implemented by caches which can set values on conditions atomically.
+// Options are the same as ones of Set method.
+type ConditionalSetter interface {
+	// Add sets value only if key is missing or expired, ErrExisted is returned otherwise.
+	Add(key, value interface{}, options ...Option) error
+
+	// Replace sets value only if key exists, ErrNotFound is returned otherwise.
+	Replace(key, value interface{}, options ...Option) error
+
+	// GetWithVersion is like Get, and also returns the version of the value for CompareAndSwap.
+	GetWithVersion(key interface{}, options ...Option) (value interface{}, version uint64, err error)
+
+	// CompareAndSwap sets value only if the version of key is still the one returned by GetWithVersion.
+	// ErrVersionMismatch is returned if the value was changed, ErrNotFound if key is missing or expired.
+	CompareAndSwap(key, value interface{}, version uint64, options ...Option) error
+}
+
+// Iterator is implemented by local caches which can enumerate their live entries, expired ones are skipped.
+// Entries are snapshotted before being visited, so it's safe to use the cache while iterating.
+type Iterator interface {
+	// Keys returns the keys matching pattern, a glob of path.Match on the keys formatted by fmt.Sprint.
+	// An empty pattern matches all keys.
+	Keys(pattern string, options ...Option) ([]interface{}, error)
+
+	// Range calls fn with each key and value until fn returns false.
+	Range(fn func(key, value interface{}) bool, options ...Option) error
+}
+
+// Scanner is implemented by redis caches which can enumerate their keys by cursor, like SCAN of redis.
+// A key may be returned more than once, and keys added or removed during the iteration may be missed.
+type Scanner interface {
+	// Scan returns the keys matching the glob-style match of a page from cursor, about count of them,
+	// and the cursor of the next page which is 0 if the iteration is complete. Iteration starts with cursor 0.
+	Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error)
+}
+
+// Closer is implemented by caches which hold resources such as background goroutines.
+type Closer interface {
+	// Close stops background work and waits for the work in flight until ctx is done.
+	// Operations after Close return ErrClosed.
+	Close(ctx context.Context) error
+}
+
+// closeCache closes c if it's a Closer.
+func closeCache(ctx context.Context, c Cache) error {
+	if closer, ok := c.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// Sweepable is implemented by caches which can be swept by a Sweeper.
+type Sweepable interface {
+	// SweepSample samples at most n keys having ttl, removes the expired ones of them,
+	// and returns the number of keys sampled and removed.
+	SweepSample(n int) (sampled, removed int, err error)
+}
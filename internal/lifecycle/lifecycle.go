@@ -0,0 +1,98 @@
+// Package lifecycle tracks the closing of caches and their background goroutines.
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+)
+
+// Lifecycle is closed once, which stops the goroutines started by Go and waits for them.
+type Lifecycle struct {
+	mu     sync.Mutex
+	closed int32
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{
+		done: make(chan struct{}),
+	}
+}
+
+// Go runs fn in a goroutine, done is closed when closing and fn should return then.
+// fn is not run and false is returned if closed already.
+func (l *Lifecycle) Go(fn func(done <-chan struct{})) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed == 1 {
+		return false
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.done)
+	}()
+	return true
+}
+
+// Err returns cache.ErrClosed if closed, otherwise nil.
+func (l *Lifecycle) Err() error {
+	if atomic.LoadInt32(&l.closed) == 1 {
+		return cache.ErrClosed
+	}
+	return nil
+}
+
+// Close marks closed, stops goroutines and waits for them until ctx is done.
+// cache.ErrClosed is returned if closed already.
+func (l *Lifecycle) Close(ctx context.Context) error {
+	l.mu.Lock()
+	if l.closed == 1 {
+		l.mu.Unlock()
+		return cache.ErrClosed
+	}
+	atomic.StoreInt32(&l.closed, 1)
+	close(l.done)
+	l.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Every calls fn every interval of clock in a goroutine until closing, not if interval is not positive.
+func (l *Lifecycle) Every(clock cache.Clock, interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+	// the ticker starts now rather than when the goroutine is scheduled
+	tick := clock.NewTicker(interval)
+	started := l.Go(func(done <-chan struct{}) {
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-tick.C():
+				fn()
+			case <-done:
+				return
+			}
+		}
+	})
+	if !started {
+		tick.Stop()
+	}
+}
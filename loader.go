@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Loader loads the value of a key which is missing in cache.
+type Loader interface {
+	// Load Retrieves the value of key from the origin, such as database.
+	Load(ctx context.Context, key interface{}) (interface{}, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as Loader.
+type LoaderFunc func(ctx context.Context, key interface{}) (interface{}, error)
+
+// Load calls f(ctx, key).
+func (f LoaderFunc) Load(ctx context.Context, key interface{}) (interface{}, error) {
+	return f(ctx, key)
+}
+
+type loadKey struct {
+	c   Cache
+	key interface{}
+}
+
+var defaultGroup group
+
+// GetOrLoad Retrieves a value from cache with a specified key.
+// If key is not found, the value is loaded by loader and stored into cache.
+// Concurrent misses of the same key in the same cache are coalesced into one loader call,
+// which runs with the context of the first caller.
+// Errors of loader are returned and never cached, error of storing the loaded value is ignored.
+// If the cache implements StaleGetter, a stale value is returned at once and refreshed by loader in background.
+// The time taken by loader is stored along with the value for cache.WithEarlyExpiration().
+// Option supports cache.WithContext(), cache.WithTTL(), cache.WithStaleTTL(), cache.WithRefreshAhead()
+// and cache.WithEarlyExpiration()
+func GetOrLoad(c Cache, key interface{}, loader Loader, options ...Option) (interface{}, error) {
+	return getOrLoad(&defaultGroup, c, key, loader, options...)
+}
+
+func getOrLoad(g *group, c Cache, key interface{}, loader Loader, options ...Option) (interface{}, error) {
+	v, err := get(g, c, key, loader, options...)
+	if err != ErrNotFound {
+		return v, err
+	}
+
+	return g.do(loadKey{c, key}, func() (interface{}, error) {
+		return load(c, key, loader, options...)
+	})
+}
+
+// get Retrieves a value from cache, a stale value is returned while it's refreshed by loader in background.
+func get(g *group, c Cache, key interface{}, loader Loader, options ...Option) (interface{}, error) {
+	sg, ok := c.(StaleGetter)
+	if !ok {
+		return c.Get(key, options...)
+	}
+
+	v, stale, err := sg.GetStale(key, options...)
+	if err == nil && stale {
+		// the refresh outlives the caller, so it doesn't share the caller's context
+		refreshOptions := make([]Option, 0, len(options)+1)
+		refreshOptions = append(refreshOptions, options...)
+		refreshOptions = append(refreshOptions, WithContext(context.Background()))
+		g.doAsync(loadKey{c, key}, func() (interface{}, error) {
+			return load(c, key, loader, refreshOptions...)
+		})
+	}
+	return v, err
+}
+
+// load loads the value of key by loader and stores it into cache.
+// The value returned is in the same form as the one returned by c.Get().
+func load(c Cache, key interface{}, loader Loader, options ...Option) (interface{}, error) {
+	var o Options
+	o.Apply(options...)
+
+	start := time.Now()
+	v, err := loader.Load(o.Ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	setOptions := make([]Option, 0, len(options)+1)
+	setOptions = append(setOptions, WithRecomputeTime(time.Since(start)))
+	setOptions = append(setOptions, options...)
+	_ = c.Set(key, v, setOptions...)
+
+	return stored(c, v)
+}
+
+// stored converts a loaded value to the form returned by c.Get().
+func stored(c Cache, v interface{}) (interface{}, error) {
+	codec := c.Codec()
+	if codec == nil {
+		return v, nil
+	}
+	b, err := codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(b)
+}
+
+var _ Cache = (*loadingCache)(nil)
+
+type loadingCache struct {
+	Cache
+	loader Loader
+	g      group
+}
+
+// NewLoadingCache makes c read-through, missing keys of Get and MGet are loaded by loader.
+// Loader may return ErrNotFound for keys absent in the origin, which are left out of MGet result.
+func NewLoadingCache(c Cache, loader Loader) *loadingCache {
+	return &loadingCache{
+		Cache:  c,
+		loader: loader,
+	}
+}
+
+// Close closes the underlying cache if it's a cache.Closer.
+func (c *loadingCache) Close(ctx context.Context) error {
+	return closeCache(ctx, c.Cache)
+}
+
+func (c *loadingCache) Get(key interface{}, options ...Option) (interface{}, error) {
+	return getOrLoad(&c.g, c.Cache, key, c.loader, options...)
+}
+
+func (c *loadingCache) MGet(keys []interface{}, options ...Option) (map[interface{}]interface{}, error) {
+	ret, err := c.Cache.MGet(keys, options...)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = make(map[interface{}]interface{})
+	}
+
+	for _, key := range keys {
+		if _, ok := ret[key]; ok {
+			continue
+		}
+		v, err := c.g.do(loadKey{c.Cache, key}, func() (interface{}, error) {
+			return load(c.Cache, key, c.loader, options...)
+		})
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret[key] = v
+	}
+	return ret, nil
+}
@@ -0,0 +1,185 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetOrLoad(t *testing.T) {
+	c := local.NewLocalCache()
+	var calls int32
+	loader := cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond) // slow origin
+		return fmt.Sprintf("value of %v", key), nil
+	})
+
+	n := 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(c, "key", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "value of key", v)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// hit the cache
+	v, err := cache.GetOrLoad(c, "key", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "value of key", v)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_GetOrLoadError(t *testing.T) {
+	c := local.NewLocalCache()
+	errOrigin := errors.New("origin is down")
+	loader := cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return nil, errOrigin
+	})
+
+	_, err := cache.GetOrLoad(c, "key", loader)
+	assert.Equal(t, errOrigin, err)
+
+	ok, err := c.Exists("key")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func Test_GetOrLoadTTL(t *testing.T) {
+	c := local.NewLocalCache()
+	loader := cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return key, nil
+	})
+
+	v, err := cache.GetOrLoad(c, 1, loader, cache.WithTTL(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	ok, err := c.Exists(1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func Test_LoadingCacheMGet(t *testing.T) {
+	var calls int32
+	c := cache.NewLoadingCache(local.NewLocalCache(), cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if key.(int) >= 10 {
+			return nil, cache.ErrNotFound
+		}
+		return key, nil
+	}))
+
+	n := 10
+	for i := 0; i < n/2; i++ {
+		c.Set(i, i)
+	}
+
+	keys := make([]interface{}, 0, n*2)
+	for i := 0; i < n*2; i++ {
+		keys = append(keys, i)
+	}
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, n, len(ret))
+	for i := 0; i < n; i++ {
+		assert.Equal(t, i, ret[i])
+	}
+	assert.Equal(t, int32(n*2-n/2), atomic.LoadInt32(&calls))
+
+	v, err := c.Get(n - 1)
+	assert.NoError(t, err)
+	assert.Equal(t, n-1, v)
+	_, err = c.Get(n)
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_GetOrLoadStale(t *testing.T) {
+	c := local.NewLocalCache()
+	var calls int32
+	loader := cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return int(atomic.AddInt32(&calls, 1)), nil
+	})
+	options := []cache.Option{cache.WithTTL(time.Second), cache.WithStaleTTL(time.Minute)}
+
+	v, err := cache.GetOrLoad(c, "key", loader, options...)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	time.Sleep(1500 * time.Millisecond) // wait for soft expires
+
+	// the stale value is returned at once, and refreshed in background
+	v, err = cache.GetOrLoad(c, "key", loader, options...)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	time.Sleep(100 * time.Millisecond) // wait for refreshing
+	v, err = cache.GetOrLoad(c, "key", loader, options...)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_GetOrLoadPanic(t *testing.T) {
+	c := local.NewLocalCache()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicking := cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		panic("loader is broken")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.PanicsWithValue(t, "loader is broken", func() {
+			_, _ = cache.GetOrLoad(c, "key", panicking)
+		})
+	}()
+
+	// a waiter of the panicking call gets an error instead of hanging
+	<-started
+	waiter := make(chan error)
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("waiter panicked: %v", r)
+			}
+			waiter <- err
+		}()
+		_, err = cache.GetOrLoad(c, "key", cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+			return nil, errors.New("waiter called loader")
+		}))
+	}()
+	time.Sleep(50 * time.Millisecond) // wait for the waiter to join the call
+	close(release)
+	<-done
+	err := <-waiter
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loader is broken")
+
+	// the panicking call is forgotten
+	v, err := cache.GetOrLoad(c, "key", cache.LoaderFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return "value", nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+}
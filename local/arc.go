@@ -0,0 +1,445 @@
+package local
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
+)
+
+var DefaultARCCacheConfig = ARCCacheConfig{
+	TTL:        time.Hour,
+	GCInterval: 10 * time.Minute,
+	GCOnceSize: 20,
+}
+
+type ARCCacheConfig struct {
+	TTL        time.Duration
+	GCInterval time.Duration
+	GCOnceSize int
+	// Clock tells the time for expiration and schedules GC, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of the cache.
+	OnEvict cache.EvictFunc
+}
+
+var _ cache.Cache = (*arcCache)(nil)
+var _ cache.Closer = (*arcCache)(nil)
+var _ cache.StatsReporter = (*arcCache)(nil)
+
+// arcCache is an Adaptive Replacement Cache.
+// t1 holds keys seen once recently, t2 holds keys seen at least twice recently.
+// b1 and b2 are ghost lists holding only the keys recently evicted from t1 and t2.
+// A hit in b1 grows the target size p of t1 toward recency, a hit in b2 shrinks it toward frequency.
+// Entries are bounded by count only, Set with cache.WithCost() returns cache.ErrUnsupported.
+type arcCache struct {
+	Cap int
+	ARCCacheConfig
+
+	p         int // target size of t1
+	t1, t2    *list.List
+	b1, b2    *list.List
+	nodeIndex map[interface{}]*arcNode
+	bytes     int64 // estimated bytes of entries, ghosts are not counted
+	mutex     sync.Mutex
+	lc        *lifecycle.Lifecycle
+	stats     cache.StatsCounter
+	evicts    evictQueue
+}
+
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+type arcNode struct {
+	node
+	where int
+	el    *list.Element
+}
+
+func NewARCCache(cap int) *arcCache {
+	return NewARCCacheWithConfig(cap, DefaultARCCacheConfig)
+}
+
+func NewARCCacheWithConfig(cap int, cfg ARCCacheConfig) *arcCache {
+	c := &arcCache{
+		Cap:            cap,
+		ARCCacheConfig: cfg,
+		t1:             list.New(),
+		t2:             list.New(),
+		b1:             list.New(),
+		b2:             list.New(),
+		nodeIndex:      make(map[interface{}]*arcNode),
+		lc:             lifecycle.New(),
+		evicts:         evictQueue{fn: cfg.OnEvict},
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	c.lc.Every(c.Clock, c.GCInterval, c.GC)
+	return c
+}
+
+func (c *arcCache) lock() {
+	c.mutex.Lock()
+}
+
+// unlock unlocks the cache, and then calls OnEvict with the entries left while it's locked.
+func (c *arcCache) unlock() {
+	queue := c.evicts.take()
+	c.mutex.Unlock()
+	c.evicts.call(queue)
+}
+
+// Close stops the background GC and waits for it until ctx is done.
+func (c *arcCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
+func (c *arcCache) GC() {
+	c.lock()
+	defer c.unlock()
+	defer c.stats.AddGCRuns(1)
+
+	removedNum := 0
+	now := c.Clock.Now()
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for e := l.Front(); e != nil; {
+			if removedNum > c.GCOnceSize {
+				return
+			}
+			next := e.Next()
+			n := e.Value.(*arcNode)
+			if c.nodeIsExpired(n, now) {
+				removedNum++
+				c.reclaim(n.key)
+			}
+			e = next
+		}
+	}
+}
+
+func (c *arcCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+	v, err := c.get(key)
+	c.stats.Hit(err == nil)
+	return v, err
+}
+
+func (c *arcCache) Set(key interface{}, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+	return c.set(key, value, &o)
+}
+
+func (c *arcCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	ret := make(map[interface{}]interface{})
+	for _, key := range keys {
+		v, err := c.get(key)
+		if err != nil {
+			continue
+		}
+		ret[key] = v
+	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
+	return ret, nil
+}
+
+func (c *arcCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	for k, v := range keyValues {
+		if err := c.set(k, v, &o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *arcCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.nodeIndex[key]
+	if !ok || n.isGhost() {
+		return false, nil
+	}
+	if c.nodeIsExpired(n, c.Clock.Now()) {
+		c.reclaim(key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *arcCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if n, ok := c.nodeIndex[key]; ok && !n.isGhost() {
+		c.stats.AddDeletes(1)
+	}
+	return c.del(key, cache.EvictDeleted)
+}
+
+func (c *arcCache) Clear(options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.stats.AddDeletes(c.t1.Len() + c.t2.Len())
+	if c.OnEvict != nil {
+		for _, l := range []*list.List{c.t1, c.t2} {
+			for e := l.Front(); e != nil; e = e.Next() {
+				n := e.Value.(*arcNode)
+				c.evicts.push(n.key, n.value, cache.EvictCleared)
+			}
+		}
+	}
+	c.bytes = 0
+	c.p = 0
+	c.t1 = list.New()
+	c.t2 = list.New()
+	c.b1 = list.New()
+	c.b2 = list.New()
+	c.nodeIndex = make(map[interface{}]*arcNode)
+	return nil
+}
+
+func (c *arcCache) Codec() cache.Codec {
+	return nil
+}
+
+// Stats reports the entries and their estimated bytes kept on writes, expired entries are unknown.
+// Keys of the ghost lists are not entries.
+func (c *arcCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.lock()
+	defer c.unlock()
+	stats.Entries = int64(c.t1.Len() + c.t2.Len())
+	stats.Expired = -1
+	stats.Bytes = c.bytes
+	return stats, nil
+}
+
+func (n *arcNode) isGhost() bool {
+	return n.where == arcB1 || n.where == arcB2
+}
+
+func (c *arcCache) list(where int) *list.List {
+	switch where {
+	case arcT1:
+		return c.t1
+	case arcT2:
+		return c.t2
+	case arcB1:
+		return c.b1
+	default:
+		return c.b2
+	}
+}
+
+// del removes key for reason, keys of the ghost lists leave silently.
+func (c *arcCache) del(key interface{}, reason cache.EvictReason) error {
+	if n, ok := c.nodeIndex[key]; ok {
+		if !n.isGhost() {
+			c.evicts.push(key, n.value, reason)
+		}
+		c.list(n.where).Remove(n.el)
+		c.bytes -= n.size
+		delete(c.nodeIndex, key)
+	}
+	return nil
+}
+
+// reclaim removes the expired key.
+func (c *arcCache) reclaim(key interface{}) {
+	c.del(key, cache.EvictExpired)
+	c.stats.AddExpirations(1)
+}
+
+// move moves n to the most recently used position of the list where.
+func (c *arcCache) move(n *arcNode, where int) {
+	c.list(n.where).Remove(n.el)
+	n.where = where
+	n.el = c.list(where).PushBack(n)
+}
+
+func (c *arcCache) nodeIsExpired(n *arcNode, deadline time.Time) bool {
+	ttl := c.TTL
+	if n.ttl > 0 {
+		ttl = n.ttl
+	}
+	if deadline.Sub(n.lastVisit) > ttl {
+		return true
+	}
+	return false
+}
+
+func (c *arcCache) get(key interface{}) (interface{}, error) {
+	n, ok := c.nodeIndex[key]
+	if !ok || n.isGhost() {
+		return nil, cache.ErrNotFound
+	}
+
+	now := c.Clock.Now()
+	if c.nodeIsExpired(n, now) {
+		c.reclaim(key)
+		return nil, cache.ErrNotFound
+	}
+	n.lastVisit = now
+	c.move(n, arcT2)
+
+	return n.value, nil
+}
+
+func (c *arcCache) set(key, value interface{}, o *cache.Options) error {
+	if o.Cost > 0 {
+		return cache.ErrUnsupported
+	}
+
+	c.stats.AddSets(1)
+	now := c.Clock.Now()
+	n, ok := c.nodeIndex[key]
+	if ok && !n.isGhost() {
+		reason := cache.EvictReplaced
+		if c.nodeIsExpired(n, now) {
+			reason = cache.EvictExpired
+		}
+		c.evicts.push(key, n.value, reason)
+		c.bytes += n.setValue(value)
+		n.ttl = o.TTL
+		n.lastVisit = now
+		c.move(n, arcT2)
+		return nil
+	}
+
+	if c.Cap <= 0 { // no limit, ghosts are useless
+		n = &arcNode{node: node{key: key, lastVisit: now, ttl: o.TTL}, where: arcT1}
+		c.bytes += n.setValue(value)
+		n.el = c.t1.PushBack(n)
+		c.nodeIndex[key] = n
+		return nil
+	}
+
+	if ok { // ghost hit, adapt the target size of t1
+		b1, b2 := c.b1.Len(), c.b2.Len()
+		if n.where == arcB1 {
+			delta := 1
+			if b2 > b1 {
+				delta = b2 / b1
+			}
+			c.p += delta
+			if c.p > c.Cap {
+				c.p = c.Cap
+			}
+		} else {
+			delta := 1
+			if b1 > b2 {
+				delta = b1 / b2
+			}
+			c.p -= delta
+			if c.p < 0 {
+				c.p = 0
+			}
+		}
+		c.replace(n.where == arcB2)
+		c.bytes += n.setValue(value)
+		n.ttl = o.TTL
+		n.lastVisit = now
+		c.move(n, arcT2)
+		return nil
+	}
+
+	// a new key
+	l1 := c.t1.Len() + c.b1.Len()
+	l2 := c.t2.Len() + c.b2.Len()
+	if l1 >= c.Cap {
+		if c.t1.Len() < c.Cap {
+			c.del(c.b1.Front().Value.(*arcNode).key, cache.EvictCapacity)
+			c.replace(false)
+		} else { // b1 is empty
+			c.del(c.t1.Front().Value.(*arcNode).key, cache.EvictCapacity)
+			c.stats.AddEvictions(1)
+		}
+	} else if l1+l2 >= c.Cap {
+		if l1+l2 >= 2*c.Cap {
+			c.del(c.b2.Front().Value.(*arcNode).key, cache.EvictCapacity)
+		}
+		c.replace(false)
+	}
+	n = &arcNode{node: node{key: key, lastVisit: now, ttl: o.TTL}, where: arcT1}
+	c.bytes += n.setValue(value)
+	n.el = c.t1.PushBack(n)
+	c.nodeIndex[key] = n
+	return nil
+}
+
+// replace evicts the least recently used key of t1 or t2 to its ghost list, according to the target size p.
+func (c *arcCache) replace(inB2 bool) {
+	if c.t1.Len()+c.t2.Len() < c.Cap {
+		return
+	}
+	t1 := c.t1.Len()
+	if t1 > 0 && (t1 > c.p || (inB2 && t1 == c.p)) {
+		c.ghost(c.t1.Front().Value.(*arcNode), arcB1)
+	} else if c.t2.Len() > 0 {
+		c.ghost(c.t2.Front().Value.(*arcNode), arcB2)
+	}
+}
+
+// ghost evicts the value of n, and keeps its key in the ghost list where.
+func (c *arcCache) ghost(n *arcNode, where int) {
+	c.evicts.push(n.key, n.value, cache.EvictCapacity)
+	c.bytes -= n.size
+	n.size = 0
+	n.value = nil
+	c.move(n, where)
+	c.stats.AddEvictions(1)
+}
@@ -0,0 +1,190 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ARCCacheGet(t *testing.T) {
+	n := 10
+	c := NewARCCache(n)
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			c.Set(fmt.Sprintf("%d", i), i, cache.WithTTL(time.Second))
+		} else {
+			c.Set(fmt.Sprintf("%d", i), i)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		if i == 0 {
+			assert.Equal(t, cache.ErrNotFound, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, i, v)
+		}
+	}
+}
+
+func Test_ARCCacheCap(t *testing.T) {
+	cap := 100
+	c := NewARCCache(cap)
+	for i := 0; i < cap*10; i++ {
+		c.Set(i%(cap*3), i)
+		if i%7 == 0 {
+			c.Get(i % cap)
+		}
+		assert.True(t, c.t1.Len()+c.t2.Len() <= cap)
+		assert.True(t, c.t1.Len()+c.b1.Len() <= cap)
+		assert.True(t, c.t1.Len()+c.t2.Len()+c.b1.Len()+c.b2.Len() <= 2*cap)
+	}
+
+	assert.NoError(t, c.Clear())
+	assert.Equal(t, 0, len(c.nodeIndex))
+}
+
+func Test_ARCCacheAdapt(t *testing.T) {
+	cap := 10
+	c := NewARCCache(cap)
+
+	// frequent keys are kept in t2 during a scan
+	for i := 0; i < cap/2; i++ {
+		c.Set(i, i)
+		c.Get(i)
+	}
+	for i := cap; i < cap*5; i++ {
+		c.Set(i, i)
+	}
+	for i := 0; i < cap/2; i++ {
+		ok, _ := c.Exists(i)
+		assert.True(t, ok, i)
+	}
+
+	// ghost hits in b1 grow the target size of t1
+	assert.Equal(t, 0, c.p)
+	assert.True(t, c.b1.Len() > 0)
+	ghost := c.b1.Front().Value.(*arcNode).key
+	ok, _ := c.Exists(ghost)
+	assert.False(t, ok)
+	c.Set(ghost, ghost)
+	assert.True(t, c.p > 0)
+	assert.Equal(t, arcT2, c.nodeIndex[ghost].where)
+}
+
+func Test_ARCCacheHitRatio(t *testing.T) {
+	cap := 1000
+	trace := scanTrace(zipfTrace(1, 100000, 200000), 100, 200)
+
+	lru := hitRatio(NewLRUCache(cap), trace)
+	arc := hitRatio(NewARCCache(cap), trace)
+	t.Logf("zipf with scans: lru %.4f, arc %.4f", lru, arc)
+	assert.True(t, arc > lru)
+}
+
+func Test_ARCCacheCost(t *testing.T) {
+	c := NewARCCache(10)
+	assert.Equal(t, cache.ErrUnsupported, c.Set(1, 1, cache.WithCost(10)))
+	assert.Equal(t, cache.ErrUnsupported, c.MSet(map[interface{}]interface{}{1: 1}, cache.WithCost(10)))
+	ok, _ := c.Exists(1)
+	assert.False(t, ok)
+}
+
+func Test_ARCCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewARCCacheWithConfig(10, ARCCacheConfig{
+		TTL:        time.Minute,
+		GCInterval: time.Minute,
+		GCOnceSize: 10,
+		Clock:      clock,
+	})
+	defer c.Close(context.Background())
+
+	c.Set("default", 1)
+	c.Set("short", 2, cache.WithTTL(time.Second))
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// the background GC is scheduled by the clock
+	clock.Advance(2 * time.Minute)
+	assert.Eventually(t, func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return len(c.nodeIndex) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_ARCCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewARCCacheWithConfig(2, ARCCacheConfig{TTL: time.Hour, Clock: clock})
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Get("b")
+	c.Set("c", 3) // a is evicted to the ghost list
+	c.Get("a")
+	c.MGet([]interface{}{"b", "c"})
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries:   2,
+		Expired:   -1, // unknown
+		Bytes:     18,
+		Hits:      3,
+		Misses:    1,
+		Evictions: 1,
+		Sets:      3,
+	}, s)
+
+	c.Get("b")
+	c.Set("a", "12345") // a ghost hit
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Expirations)
+	assert.Equal(t, int64(2), s.Entries)
+	assert.Equal(t, int64(15), s.Bytes)
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(2), s.Deletes)
+	assert.Equal(t, int64(0), s.Bytes)
+}
+
+func Test_ARCCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *arcCache
+	var evicted []string
+	c = NewARCCacheWithConfig(2, ARCCacheConfig{TTL: time.Hour, Clock: clock, OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%v=%v %v", key, value, reason))
+		c.Exists(key) // the cache isn't locked
+	}})
+
+	c.Set("a", 1)
+	c.Set("a", 2) // a is moved to t2
+	c.Set("b", 1)
+	c.Set("c", 1, cache.WithTTL(time.Second)) // b is evicted to the ghost list
+	clock.Advance(time.Minute)
+	c.Get("c")
+	c.Delete("a")
+	c.Set("d", 1)
+	c.Clear()
+	assert.Equal(t, []string{"a=1 replaced", "b=1 capacity", "c=1 expired", "a=2 deleted", "d=1 cleared"}, evicted)
+}
@@ -0,0 +1,498 @@
+package local
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
+)
+
+var DefaultArenaCacheConfig = ArenaCacheConfig{
+	Shards:    16,
+	ShardSize: 256 << 10, // 4MB in total
+}
+
+type ArenaCacheConfig struct {
+	// Shards is the number of shards, rounded up to a power of two.
+	Shards int
+	// ShardSize is the bytes of the ring buffer of each shard, allocated at once.
+	// It must be greater than 0 and less than 4GB, which offsets of the index can address.
+	ShardSize int
+	// TTL is the default ttl of entries, not expire if equal 0.
+	TTL time.Duration
+	// Clock tells the time for expiration, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of its shard.
+	// Keys are the strings of key bytes, and values are decoded by the codec.
+	OnEvict cache.EvictFunc
+}
+
+// ErrEntryTooLarge is returned when an entry never fits, larger than a shard of arena cache
+// or MaxCost of lru and lfu caches. The entry stored with the key, if any, is kept.
+var ErrEntryTooLarge = cache.NewCacheError(errors.New("entry too large"))
+
+var _ cache.Cache = (*arenaCache)(nil)
+var _ cache.Closer = (*arenaCache)(nil)
+var _ cache.StatsReporter = (*arenaCache)(nil)
+
+// arenaCache stores encoded entries in pre-allocated byte ring buffers, indexed by map[uint64]uint32
+// from key hash to the offset in buffer. Neither the buffers nor the index contain pointers,
+// so the cache adds nearly nothing to GC scan work regardless of its size.
+// When a buffer is full, the oldest entries are overwritten.
+// Keys are converted to bytes, integers and strings of the same text are the same key.
+type arenaCache struct {
+	ArenaCacheConfig
+	codec  cache.Codec
+	shards []*arenaShard
+	mask   uint64
+	seed   maphash.Seed
+	lc     *lifecycle.Lifecycle
+}
+
+// NewArenaCache creates an arena cache, codec must not be nil.
+// It returns an error if cfg.ShardSize is out of range.
+func NewArenaCache(codec cache.Codec, cfg ArenaCacheConfig) (*arenaCache, error) {
+	if cfg.ShardSize <= 0 || int64(cfg.ShardSize) >= 1<<32 {
+		return nil, cache.NewCacheError(fmt.Errorf("shard size %d out of range (0, 4GB)", cfg.ShardSize))
+	}
+	n := 1
+	for n < cfg.Shards {
+		n <<= 1
+	}
+	c := &arenaCache{
+		ArenaCacheConfig: cfg,
+		codec:            codec,
+		shards:           make([]*arenaShard, n),
+		mask:             uint64(n - 1),
+		seed:             maphash.MakeSeed(),
+		lc:               lifecycle.New(),
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	var onEvict cache.EvictFunc
+	if cfg.OnEvict != nil {
+		onEvict = func(key, value interface{}, reason cache.EvictReason) {
+			v, err := codec.Decode(value.([]byte))
+			if err != nil {
+				return
+			}
+			cfg.OnEvict(key, v, reason)
+		}
+	}
+	for i := range c.shards {
+		c.shards[i] = newArenaShard(cfg.ShardSize, onEvict)
+	}
+	return c, nil
+}
+
+// Close makes later operations return cache.ErrClosed, and releases the buffers.
+func (c *arenaCache) Close(ctx context.Context) error {
+	if err := c.lc.Close(ctx); err != nil {
+		return err
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.buf = nil
+		s.reset()
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Footprint returns the approximate bytes used by the cache, including buffers and indexes.
+func (c *arenaCache) Footprint() int64 {
+	var ret int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		ret += int64(len(s.buf)) + int64(len(s.index))*arenaIndexEntrySize
+		s.mu.Unlock()
+	}
+	return ret
+}
+
+func (c *arenaCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	b, err := c.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Decode(b)
+}
+
+func (c *arenaCache) Set(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+	return c.set(key, value, &o, c.Clock.Now())
+}
+
+func (c *arenaCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[interface{}]interface{})
+	for _, key := range keys {
+		b, err := c.get(key)
+		if err != nil {
+			continue
+		}
+		v, err := c.codec.Decode(b)
+		if err != nil {
+			continue
+		}
+		ret[key] = v
+	}
+	return ret, nil
+}
+
+func (c *arenaCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	now := c.Clock.Now()
+	for k, v := range keyValues {
+		if err := c.set(k, v, &o, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *arenaCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	kb, err := c.keyBytes(key)
+	if err != nil {
+		return false, err
+	}
+	h := maphash.Bytes(c.seed, kb)
+	s := c.shards[h&c.mask]
+
+	s.lock()
+	defer s.unlock()
+	_, ok := s.lookup(h, kb, c.Clock.Now())
+	return ok, nil
+}
+
+func (c *arenaCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	kb, err := c.keyBytes(key)
+	if err != nil {
+		return err
+	}
+	h := maphash.Bytes(c.seed, kb)
+	s := c.shards[h&c.mask]
+
+	s.lock()
+	defer s.unlock()
+	if _, ok := s.lookup(h, kb, c.Clock.Now()); ok {
+		s.drop(h, cache.EvictDeleted)
+		s.stats.AddDeletes(1)
+	}
+	return nil
+}
+
+func (c *arenaCache) Clear(options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range c.shards {
+		s.lock()
+		s.stats.AddDeletes(len(s.index))
+		if s.evicts.fn != nil {
+			for h := range s.index {
+				s.drop(h, cache.EvictCleared)
+			}
+		}
+		s.reset()
+		s.unlock()
+	}
+	return nil
+}
+
+func (c *arenaCache) Codec() cache.Codec {
+	return c.codec
+}
+
+// Stats sums the counters and sizes of shards, Bytes are the encoded entries alive in buffers.
+// Expired entries are unknown, they are counted in Entries until visited or overwritten.
+func (c *arenaCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	var stats cache.Stats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		ss := s.stats.Stats()
+		ss.Entries = int64(len(s.index))
+		ss.Bytes = s.bytes
+		s.mu.Unlock()
+		stats.Add(ss)
+	}
+	stats.Expired = -1
+	return stats, nil
+}
+
+// get returns a copy of the encoded value of key.
+func (c *arenaCache) get(key interface{}) ([]byte, error) {
+	kb, err := c.keyBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	h := maphash.Bytes(c.seed, kb)
+	s := c.shards[h&c.mask]
+
+	s.lock()
+	defer s.unlock()
+	v, ok := s.lookup(h, kb, c.Clock.Now())
+	s.stats.Hit(ok)
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (c *arenaCache) set(key, value interface{}, o *cache.Options, now time.Time) error {
+	kb, err := c.keyBytes(key)
+	if err != nil {
+		return err
+	}
+	if len(kb) > 0xffff {
+		return ErrEntryTooLarge
+	}
+	vb, err := c.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	ttl := c.TTL
+	if o.TTL > 0 {
+		ttl = o.TTL
+	}
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = now.Add(ttl).UnixNano()
+	}
+
+	h := maphash.Bytes(c.seed, kb)
+	s := c.shards[h&c.mask]
+
+	s.lock()
+	defer s.unlock()
+	return s.push(h, kb, vb, expireAt, now)
+}
+
+func (c *arenaCache) keyBytes(key interface{}) ([]byte, error) {
+	switch k := key.(type) {
+	case string:
+		return []byte(k), nil
+	case []byte:
+		return k, nil
+	case int:
+		return strconv.AppendInt(nil, int64(k), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(k), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, k, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(k), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(k), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, k, 10), nil
+	default:
+		return c.codec.Encode(k)
+	}
+}
+
+const (
+	// entry layout: length(4) | expireAt(8) | hash(8) | key length(2) | key | value
+	arenaHeaderSize = 4 + 8 + 8 + 2
+	// rough bytes of one map[uint64]uint32 entry
+	arenaIndexEntrySize = 16
+)
+
+// arenaShard is a ring buffer of entries in the order of writing. head is the offset of the oldest entry,
+// tail is the offset to write the next one. A length of 0, or less than 4 bytes to the end of buffer, wraps to 0.
+// Deleted or overwritten entries are removed from index only, their space is reclaimed when head passes them.
+type arenaShard struct {
+	mu      sync.Mutex
+	buf     []byte
+	head    int
+	tail    int
+	entries int   // entries between head and tail, including dead ones
+	bytes   int64 // bytes of the entries in index
+	index   map[uint64]uint32
+	stats   cache.StatsCounter
+	evicts  evictQueue
+}
+
+func newArenaShard(size int, onEvict cache.EvictFunc) *arenaShard {
+	return &arenaShard{
+		buf:    make([]byte, size),
+		index:  make(map[uint64]uint32),
+		evicts: evictQueue{fn: onEvict},
+	}
+}
+
+func (s *arenaShard) lock() {
+	s.mu.Lock()
+}
+
+// unlock unlocks the shard, and then calls OnEvict with the entries left while it's locked.
+func (s *arenaShard) unlock() {
+	queue := s.evicts.take()
+	s.mu.Unlock()
+	s.evicts.call(queue)
+}
+
+func (s *arenaShard) reset() {
+	s.head = 0
+	s.tail = 0
+	s.entries = 0
+	s.bytes = 0
+	s.index = make(map[uint64]uint32)
+}
+
+// drop removes the entry of h from index for reason, its space is reclaimed when head passes it.
+func (s *arenaShard) drop(h uint64, reason cache.EvictReason) {
+	off, ok := s.index[h]
+	if !ok {
+		return
+	}
+	e := s.buf[off:]
+	n := binary.LittleEndian.Uint32(e)
+	if s.evicts.fn != nil {
+		keyLen := int(binary.LittleEndian.Uint16(e[20:]))
+		key := string(e[arenaHeaderSize : arenaHeaderSize+keyLen])
+		value := append([]byte(nil), e[arenaHeaderSize+keyLen:n]...) // the buffer is overwritten later
+		s.evicts.push(key, value, reason)
+	}
+	s.bytes -= int64(n)
+	delete(s.index, h)
+}
+
+// expired tells whether the entry at off is expired.
+func (s *arenaShard) expired(off uint32, now time.Time) bool {
+	expireAt := int64(binary.LittleEndian.Uint64(s.buf[off+4:]))
+	return expireAt > 0 && now.UnixNano() > expireAt
+}
+
+// lookup returns the value of key in buffer, an expired one is removed from index.
+func (s *arenaShard) lookup(h uint64, key []byte, now time.Time) ([]byte, bool) {
+	off, ok := s.index[h]
+	if !ok {
+		return nil, false
+	}
+	e := s.buf[off:]
+	n := binary.LittleEndian.Uint32(e)
+	keyLen := int(binary.LittleEndian.Uint16(e[20:]))
+	if string(e[arenaHeaderSize:arenaHeaderSize+keyLen]) != string(key) { // hash collision
+		return nil, false
+	}
+	if s.expired(off, now) {
+		s.drop(h, cache.EvictExpired)
+		s.stats.AddExpirations(1)
+		return nil, false
+	}
+	return e[arenaHeaderSize+keyLen : n], true
+}
+
+func (s *arenaShard) push(h uint64, key, value []byte, expireAt int64, now time.Time) error {
+	n := arenaHeaderSize + len(key) + len(value)
+	if n > len(s.buf) {
+		return ErrEntryTooLarge
+	}
+
+	if off, ok := s.index[h]; ok { // the former value
+		reason := cache.EvictReplaced
+		if s.expired(off, now) {
+			reason = cache.EvictExpired
+		}
+		s.drop(h, reason)
+	}
+	pos := s.alloc(n)
+	e := s.buf[pos : pos+n]
+	binary.LittleEndian.PutUint32(e, uint32(n))
+	binary.LittleEndian.PutUint64(e[4:], uint64(expireAt))
+	binary.LittleEndian.PutUint64(e[12:], h)
+	binary.LittleEndian.PutUint16(e[20:], uint16(len(key)))
+	copy(e[arenaHeaderSize:], key)
+	copy(e[arenaHeaderSize+len(key):], value)
+
+	s.index[h] = uint32(pos)
+	s.entries++
+	s.bytes += int64(n)
+	s.stats.AddSets(1)
+	return nil
+}
+
+// alloc evicts the oldest entries until there are n contiguous bytes, and returns the offset of them.
+func (s *arenaShard) alloc(n int) int {
+	for {
+		if s.entries == 0 {
+			s.head = 0
+			s.tail = 0
+		}
+		if s.tail > s.head || s.entries == 0 { // live data in [head, tail)
+			if len(s.buf)-s.tail >= n {
+				pos := s.tail
+				s.tail += n
+				return pos
+			}
+			if s.head >= n { // wrap
+				if len(s.buf)-s.tail >= 4 {
+					binary.LittleEndian.PutUint32(s.buf[s.tail:], 0)
+				}
+				s.tail = n
+				return 0
+			}
+		} else if s.head-s.tail >= n { // live data in [head, end) and [0, tail)
+			pos := s.tail
+			s.tail += n
+			return pos
+		}
+		s.evict()
+	}
+}
+
+// evict removes the oldest entry.
+func (s *arenaShard) evict() {
+	if len(s.buf)-s.head < 4 || binary.LittleEndian.Uint32(s.buf[s.head:]) == 0 {
+		s.head = 0
+	}
+	e := s.buf[s.head:]
+	n := int(binary.LittleEndian.Uint32(e))
+	h := binary.LittleEndian.Uint64(e[12:])
+	if off, ok := s.index[h]; ok && int(off) == s.head {
+		s.drop(h, cache.EvictCapacity)
+		s.stats.AddEvictions(1)
+	}
+	s.head += n
+	s.entries--
+}
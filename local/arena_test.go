@@ -0,0 +1,196 @@
+package local
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/codec/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ArenaCacheGet(t *testing.T) {
+	n := 10
+	c, err := NewArenaCache(json.NewCodec(), DefaultArenaCacheConfig)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			assert.NoError(t, c.Set(i, i, cache.WithTTL(time.Second)))
+		} else {
+			assert.NoError(t, c.Set(i, i))
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(i)
+		assert.NoError(t, err)
+		var val int
+		assert.NoError(t, c.Codec().DecodeTo(v, &val))
+		assert.Equal(t, i, val)
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	for i := 0; i < n; i++ {
+		ok, err := c.Exists(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i != 0, ok)
+	}
+
+	assert.NoError(t, c.Delete(1))
+	_, err = c.Get(1)
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	assert.NoError(t, c.Clear())
+	ret, err := c.MGet([]interface{}{2, 3})
+	assert.NoError(t, err)
+	assert.Len(t, ret, 0)
+}
+
+func Test_ArenaCacheOverwrite(t *testing.T) {
+	// one shard of 1KB, holds less than 100 entries
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{Shards: 1, ShardSize: 1 << 10})
+	assert.NoError(t, err)
+	n := 1000
+	for i := 0; i < n; i++ {
+		assert.NoError(t, c.Set(fmt.Sprintf("key%d", i), i))
+		// updates leave dead entries behind
+		assert.NoError(t, c.Set(fmt.Sprintf("key%d", i), i))
+	}
+
+	var val int
+	v, err := c.Get(fmt.Sprintf("key%d", n-1))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Codec().DecodeTo(v, &val))
+	assert.Equal(t, n-1, val)
+
+	_, err = c.Get("key0")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	// all indexed entries are readable
+	s := c.shards[0]
+	assert.True(t, len(s.index) > 0)
+	for i := 0; i < n; i++ {
+		if v, err := c.Get(fmt.Sprintf("key%d", i)); err == nil {
+			assert.NoError(t, c.Codec().DecodeTo(v, &val))
+			assert.Equal(t, i, val)
+		}
+	}
+	assert.Equal(t, c.ShardSize, len(s.buf))
+
+	err = c.Set("big", make([]int, 1<<10))
+	assert.Equal(t, ErrEntryTooLarge, err)
+}
+
+func Test_ArenaCacheFootprint(t *testing.T) {
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{Shards: 3, ShardSize: 1 << 10})
+	assert.NoError(t, err)
+	assert.Len(t, c.shards, 4)
+	assert.Equal(t, int64(4<<10), c.Footprint())
+
+	c.MSet(map[interface{}]interface{}{"a": 1, "b": 2})
+	assert.Equal(t, int64(4<<10+2*arenaIndexEntrySize), c.Footprint())
+}
+
+func Test_ArenaCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{
+		Shards:    4,
+		ShardSize: 1 << 10,
+		TTL:       time.Minute,
+		Clock:     clock,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Set("default", 1))
+	assert.NoError(t, c.Set("short", 2, cache.WithTTL(time.Second)))
+
+	clock.Advance(2 * time.Second)
+	ok, _ := c.Exists("short")
+	assert.False(t, ok)
+	ok, _ = c.Exists("default")
+	assert.True(t, ok)
+
+	clock.Advance(time.Minute)
+	_, err = c.Get("default")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_ArenaCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{
+		Shards:    1,
+		ShardSize: 3 * (arenaHeaderSize + 2),
+		Clock:     clock,
+	})
+	assert.NoError(t, err)
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Set("c", 3)
+	c.Set("d", 4) // evicts a
+	c.Get("a")
+	c.MGet([]interface{}{"c", "d"})
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries:   3,
+		Expired:   -1, // unknown
+		Bytes:     3 * (arenaHeaderSize + 2),
+		Hits:      2,
+		Misses:    1,
+		Evictions: 1,
+		Sets:      4,
+	}, s)
+
+	c.Get("b")
+	assert.NoError(t, c.Delete("c"))
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Expirations)
+	assert.Equal(t, uint64(1), s.Deletes)
+	assert.Equal(t, int64(1), s.Entries)
+	assert.Equal(t, int64(arenaHeaderSize+2), s.Bytes)
+}
+
+func Test_ArenaCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *arenaCache
+	var evicted []string
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{
+		Shards:    1,
+		ShardSize: 2 * (arenaHeaderSize + 2),
+		Clock:     clock,
+		OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+			var v int
+			c.Codec().DecodeTo(value, &v)
+			evicted = append(evicted, fmt.Sprintf("%v=%v %v", key, v, reason))
+			c.Exists(key) // the shard isn't locked
+		},
+	})
+	assert.NoError(t, err)
+
+	c.Set("a", 1)
+	c.Set("a", 2) // a=1 is replaced, and overwritten as the oldest
+	c.Set("b", 1, cache.WithTTL(time.Second))
+	c.Set("c", 1) // evicts a
+	clock.Advance(time.Minute)
+	c.Get("b")
+	c.Delete("c")
+	c.Set("d", 1)
+	c.Clear()
+	assert.Equal(t, []string{"a=1 replaced", "a=2 capacity", "b=1 expired", "c=1 deleted", "d=1 cleared"}, evicted)
+}
+
+func Test_ArenaCacheShardSize(t *testing.T) {
+	for _, size := range []int{-1, 0, 1 << 32} {
+		_, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{Shards: 1, ShardSize: size})
+		assert.Error(t, err, size)
+	}
+
+	c, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{Shards: 1, ShardSize: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, ErrEntryTooLarge, c.Set("a", 1))
+}
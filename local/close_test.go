@@ -0,0 +1,63 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/codec/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func Test_Close(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	sweeper := cache.DefaultSweeperConfig
+	sweeper.Interval = time.Millisecond
+	lruCfg := DefaultLRUCacheConfig
+	lruCfg.GCInterval = time.Millisecond
+	arena, err := NewArenaCache(json.NewCodec(), ArenaCacheConfig{Shards: 4, ShardSize: 1 << 10})
+	assert.NoError(t, err)
+	caches := []cache.Cache{
+		NewLocalCache(),
+		NewLocalCacheWithConfig(LocalCacheConfig{GCInterval: time.Millisecond}),
+		NewLocalCacheWithConfig(LocalCacheConfig{TimingWheelTick: time.Millisecond}),
+		NewLocalCacheWithConfig(LocalCacheConfig{Sweeper: &sweeper}),
+		NewSimpleCache(),
+		NewLRUCache(10),
+		NewLRUCacheWithConfig(10, lruCfg),
+		NewLRUCacheWithConfig(10, LRUCacheConfig{TTL: time.Hour, Sweeper: &sweeper}),
+		NewLFUCache(10),
+		NewTinyLFUCache(10),
+		NewARCCache(10),
+		NewShardedLocalCache(4),
+		NewShardedLRUCache(10, 4),
+		arena,
+	}
+	for i, c := range caches {
+		name := fmt.Sprintf("%d: %T", i, c)
+		assert.NoError(t, c.Set(1, 1, cache.WithTTL(time.Millisecond)), name)
+		time.Sleep(5 * time.Millisecond) // let background work run
+
+		closer, ok := c.(cache.Closer)
+		if !assert.True(t, ok, name) {
+			continue
+		}
+		assert.NoError(t, closer.Close(context.Background()), name)
+		assert.Equal(t, cache.ErrClosed, closer.Close(context.Background()), name)
+
+		_, err := c.Get(1)
+		assert.Equal(t, cache.ErrClosed, err, name)
+		assert.Equal(t, cache.ErrClosed, c.Set(1, 1), name)
+		_, err = c.MGet([]interface{}{1})
+		assert.Equal(t, cache.ErrClosed, err, name)
+		assert.Equal(t, cache.ErrClosed, c.MSet(map[interface{}]interface{}{1: 1}), name)
+		_, err = c.Exists(1)
+		assert.Equal(t, cache.ErrClosed, err, name)
+		assert.Equal(t, cache.ErrClosed, c.Delete(1), name)
+		assert.Equal(t, cache.ErrClosed, c.Clear(), name)
+	}
+}
@@ -0,0 +1,47 @@
+package local
+
+import (
+	"github.com/ryanking8215/go-cache"
+)
+
+// incrInt adds delta to v which must be an integer, the result is int64.
+func incrInt(v interface{}, delta int64) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n) + delta, nil
+	case int8:
+		return int64(n) + delta, nil
+	case int16:
+		return int64(n) + delta, nil
+	case int32:
+		return int64(n) + delta, nil
+	case int64:
+		return n + delta, nil
+	case uint:
+		return int64(n) + delta, nil
+	case uint8:
+		return int64(n) + delta, nil
+	case uint16:
+		return int64(n) + delta, nil
+	case uint32:
+		return int64(n) + delta, nil
+	case uint64:
+		return int64(n) + delta, nil
+	}
+	return 0, cache.ErrTypeMismatch
+}
+
+// incrFloat adds delta to v which must be a number, the result is float64.
+func incrFloat(v interface{}, delta float64) (float64, error) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n) + delta, nil
+	case float64:
+		return n + delta, nil
+	}
+	i, err := incrInt(v, 0)
+	if err != nil {
+		return 0, err
+	}
+	return float64(i) + delta, nil
+}
@@ -0,0 +1,36 @@
+package local
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CounterConcurrent(t *testing.T) {
+	caches := map[string]cache.Counter{
+		"local":   NewLocalCache(),
+		"simple":  NewSimpleCache(),
+		"lru":     NewLRUCache(100),
+		"sharded": NewShardedLocalCache(4),
+	}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						c.Incr("cnt")
+					}
+				}()
+			}
+			wg.Wait()
+			v, err := c.IncrBy("cnt", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1000), v)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package local
+
+import "github.com/ryanking8215/go-cache"
+
+// evicted is an entry which left a cache.
+type evicted struct {
+	key    interface{}
+	value  interface{}
+	reason cache.EvictReason
+}
+
+// evictQueue queues the entries which left a cache while it's locked, so that fn is called with them
+// after the cache is unlocked, and can use the cache.
+type evictQueue struct {
+	fn    cache.EvictFunc
+	queue []evicted
+}
+
+// push queues an entry, nothing is queued if fn is nil.
+func (q *evictQueue) push(key, value interface{}, reason cache.EvictReason) {
+	if q.fn != nil {
+		q.queue = append(q.queue, evicted{key: key, value: value, reason: reason})
+	}
+}
+
+// take takes the entries queued, which is called with the cache locked.
+func (q *evictQueue) take() []evicted {
+	queue := q.queue
+	q.queue = nil
+	return queue
+}
+
+// call calls fn with the entries taken, which is called with the cache unlocked.
+func (q *evictQueue) call(queue []evicted) {
+	for _, e := range queue {
+		q.fn(e.key, e.value, e.reason)
+	}
+}
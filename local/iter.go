@@ -0,0 +1,47 @@
+package local
+
+import (
+	"fmt"
+	"path"
+)
+
+// entry is a key and value snapshotted for iteration.
+type entry struct {
+	key   interface{}
+	value interface{}
+}
+
+// matchKey tells whether key matches pattern, see cache.Iterator.
+func matchKey(pattern string, key interface{}) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, fmt.Sprint(key))
+	return ok
+}
+
+// checkPattern returns path.ErrBadPattern if pattern is malformed.
+func checkPattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// matchedKeys returns the keys of entries matching pattern.
+func matchedKeys(entries []entry, pattern string) []interface{} {
+	keys := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		if matchKey(pattern, e.key) {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// rangeEntries calls fn with each of entries until fn returns false.
+func rangeEntries(entries []entry, fn func(key, value interface{}) bool) {
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
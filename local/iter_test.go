@@ -0,0 +1,48 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SimpleCacheIterator(t *testing.T) {
+	c := NewSimpleCache()
+	c.Set("a1", 1)
+	c.Set("a2", 2)
+	c.Set("b1", 3)
+
+	keys, err := c.Keys("a?")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []interface{}{"a1", "a2"}, keys)
+
+	n := 0
+	assert.NoError(t, c.Range(func(key, value interface{}) bool {
+		n++
+		return false
+	}))
+	assert.Equal(t, 1, n)
+}
+
+func Test_ShardedCacheIterator(t *testing.T) {
+	c := NewShardedLocalCache(4)
+	for i := 0; i < 20; i++ {
+		c.Set(i, i)
+	}
+
+	keys, err := c.Keys("1*")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []interface{}{1, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, keys)
+
+	n := 0
+	assert.NoError(t, c.Range(func(key, value interface{}) bool {
+		n++
+		return n < 5
+	}))
+	assert.Equal(t, 5, n)
+
+	c = newShardedCache(2, func(int) cache.Cache { return NewARCCache(10) })
+	_, err = c.Keys("")
+	assert.Equal(t, cache.ErrUnsupported, err)
+}
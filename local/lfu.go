@@ -0,0 +1,464 @@
+package local
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
+)
+
+var DefaultLFUCacheConfig = LFUCacheConfig{
+	TTL:           time.Hour,
+	GCInterval:    10 * time.Minute,
+	GCOnceSize:    20,
+	DecayInterval: 10 * time.Minute,
+}
+
+type LFUCacheConfig struct {
+	TTL        time.Duration
+	GCInterval time.Duration
+	GCOnceSize int
+	// DecayInterval halves the frequencies of all keys periodically,
+	// so keys hot in the past don't stay forever. Not decay if equal 0.
+	DecayInterval time.Duration
+	// MaxCost bounds the total cost of entries besides Cap, not bound if equal 0.
+	MaxCost int64
+	// Cost computes the cost of an entry if not overridden by cache.WithCost(),
+	// cache.DefaultCostFunc(Encoder) is used if nil.
+	Cost cache.CostFunc
+	// Encoder sizes values by their encoded length for the default Cost, values are stored as is anyway.
+	Encoder cache.Encoder
+	// Clock tells the time for expiration and decay, and schedules GC, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of the cache.
+	OnEvict cache.EvictFunc
+}
+
+var _ cache.Cache = (*lfuCache)(nil)
+var _ cache.Closer = (*lfuCache)(nil)
+var _ cache.StatsReporter = (*lfuCache)(nil)
+
+// lfuCache evicts the least frequently used key, the least recently used one among keys with the same frequency.
+// Keys are grouped into buckets of frequency, all operations are O(1) except the decay.
+type lfuCache struct {
+	Cap int
+	LFUCacheConfig
+
+	buckets   *list.List // of *lfuBucket, in ascending order of frequency
+	nodeIndex map[interface{}]*lfuNode
+	lastDecay time.Time
+	cost      int64
+	bytes     int64 // estimated bytes of entries
+	mutex     sync.Mutex
+	lc        *lifecycle.Lifecycle
+	stats     cache.StatsCounter
+	evicts    evictQueue
+}
+
+type lfuBucket struct {
+	freq  int
+	nodes *list.List // of *lfuNode, in order of visiting
+}
+
+type lfuNode struct {
+	node
+	bucket *list.Element
+	el     *list.Element
+}
+
+func NewLFUCache(cap int) *lfuCache {
+	return NewLFUCacheWithConfig(cap, DefaultLFUCacheConfig)
+}
+
+func NewLFUCacheWithConfig(cap int, cfg LFUCacheConfig) *lfuCache {
+	c := &lfuCache{
+		Cap:            cap,
+		LFUCacheConfig: cfg,
+		buckets:        list.New(),
+		nodeIndex:      make(map[interface{}]*lfuNode),
+		lc:             lifecycle.New(),
+		evicts:         evictQueue{fn: cfg.OnEvict},
+	}
+	if c.Cost == nil {
+		c.Cost = cache.DefaultCostFunc(c.Encoder)
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	c.lastDecay = c.Clock.Now()
+	c.lc.Every(c.Clock, c.GCInterval, c.GC)
+	return c
+}
+
+func (c *lfuCache) lock() {
+	c.mutex.Lock()
+}
+
+// unlock unlocks the cache, and then calls OnEvict with the entries left while it's locked.
+func (c *lfuCache) unlock() {
+	queue := c.evicts.take()
+	c.mutex.Unlock()
+	c.evicts.call(queue)
+}
+
+// Close stops the background GC and waits for it until ctx is done.
+func (c *lfuCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
+func (c *lfuCache) GC() {
+	c.lock()
+	defer c.unlock()
+	defer c.stats.AddGCRuns(1)
+
+	removedNum := 0
+	now := c.Clock.Now()
+	c.decay(now)
+	for b := c.buckets.Front(); b != nil; {
+		nextBucket := b.Next()
+		nodes := b.Value.(*lfuBucket).nodes
+		for e := nodes.Front(); e != nil; {
+			if removedNum > c.GCOnceSize {
+				return
+			}
+			next := e.Next()
+			n := e.Value.(*lfuNode)
+			if c.nodeIsExpired(n, now) {
+				removedNum++
+				c.reclaim(n.key)
+			}
+			e = next
+		}
+		b = nextBucket
+	}
+}
+
+func (c *lfuCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+	v, err := c.get(key)
+	c.stats.Hit(err == nil)
+	return v, err
+}
+
+func (c *lfuCache) Set(key interface{}, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+	return c.set(key, value, &o)
+}
+
+func (c *lfuCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	ret := make(map[interface{}]interface{})
+	for _, key := range keys {
+		v, err := c.get(key)
+		if err != nil {
+			continue
+		}
+		ret[key] = v
+	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
+	return ret, nil
+}
+
+func (c *lfuCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	for k, v := range keyValues {
+		if err := c.set(k, v, &o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *lfuCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.nodeIndex[key]
+	if !ok {
+		return false, nil
+	}
+	if c.nodeIsExpired(n, c.Clock.Now()) {
+		c.reclaim(key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *lfuCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if _, ok := c.nodeIndex[key]; ok {
+		c.stats.AddDeletes(1)
+	}
+	return c.del(key, cache.EvictDeleted)
+}
+
+func (c *lfuCache) Clear(options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.stats.AddDeletes(len(c.nodeIndex))
+	if c.OnEvict != nil {
+		for _, n := range c.nodeIndex {
+			c.evicts.push(n.key, n.value, cache.EvictCleared)
+		}
+	}
+	c.nodeIndex = make(map[interface{}]*lfuNode)
+	c.buckets = list.New()
+	c.cost = 0
+	c.bytes = 0
+	return nil
+}
+
+func (c *lfuCache) Codec() cache.Codec {
+	return nil
+}
+
+// Stats reports the entries and their estimated bytes kept on writes, expired entries are unknown.
+func (c *lfuCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.lock()
+	defer c.unlock()
+	stats.Entries = int64(len(c.nodeIndex))
+	stats.Expired = -1
+	stats.Bytes = c.bytes
+	return stats, nil
+}
+
+// del removes key for reason.
+func (c *lfuCache) del(key interface{}, reason cache.EvictReason) error {
+	if n, ok := c.nodeIndex[key]; ok {
+		c.evicts.push(key, n.value, reason)
+		c.cost -= n.cost
+		c.bytes -= n.size
+		c.unlink(n)
+		delete(c.nodeIndex, key)
+	}
+	return nil
+}
+
+// reclaim removes the expired key.
+func (c *lfuCache) reclaim(key interface{}) {
+	c.del(key, cache.EvictExpired)
+	c.stats.AddExpirations(1)
+}
+
+// unlink removes n from its bucket, and the bucket if it becomes empty.
+func (c *lfuCache) unlink(n *lfuNode) {
+	b := n.bucket.Value.(*lfuBucket)
+	b.nodes.Remove(n.el)
+	if b.nodes.Len() == 0 {
+		c.buckets.Remove(n.bucket)
+	}
+}
+
+// link adds n to the bucket of freq, which is created after mark, or at front if mark is nil.
+func (c *lfuCache) link(n *lfuNode, freq int, mark *list.Element) {
+	var be *list.Element
+	switch {
+	case mark != nil && mark.Value.(*lfuBucket).freq == freq:
+		be = mark
+	case mark != nil && mark.Next() != nil && mark.Next().Value.(*lfuBucket).freq == freq:
+		be = mark.Next()
+	case mark == nil && c.buckets.Front() != nil && c.buckets.Front().Value.(*lfuBucket).freq == freq:
+		be = c.buckets.Front()
+	default:
+		b := &lfuBucket{freq: freq, nodes: list.New()}
+		if mark != nil {
+			be = c.buckets.InsertAfter(b, mark)
+		} else {
+			be = c.buckets.PushFront(b)
+		}
+	}
+	n.bucket = be
+	n.el = be.Value.(*lfuBucket).nodes.PushBack(n)
+}
+
+// touch increases the frequency of n.
+func (c *lfuCache) touch(n *lfuNode) {
+	freq := n.bucket.Value.(*lfuBucket).freq + 1
+	mark := n.bucket
+	if n.bucket.Value.(*lfuBucket).nodes.Len() == 1 {
+		// the bucket is removed when n leaves, link after the one before it
+		mark = n.bucket.Prev()
+	}
+	c.unlink(n)
+	c.link(n, freq, mark)
+}
+
+func (c *lfuCache) nodeIsExpired(n *lfuNode, deadline time.Time) bool {
+	ttl := c.TTL
+	if n.ttl > 0 {
+		ttl = n.ttl
+	}
+	if deadline.Sub(n.lastVisit) > ttl {
+		return true
+	}
+	return false
+}
+
+func (c *lfuCache) get(key interface{}) (interface{}, error) {
+	now := c.Clock.Now()
+	c.decay(now)
+
+	n, ok := c.nodeIndex[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+
+	if c.nodeIsExpired(n, now) {
+		c.reclaim(key)
+		return nil, cache.ErrNotFound
+	}
+	n.lastVisit = now
+	c.touch(n)
+
+	return n.value, nil
+}
+
+func (c *lfuCache) set(key, value interface{}, o *cache.Options) error {
+	now := c.Clock.Now()
+	c.decay(now)
+
+	cost := o.Cost
+	if cost <= 0 {
+		cost = c.Cost(key, value)
+	}
+	if c.MaxCost > 0 && cost > c.MaxCost { // never fits, not flush the others for it
+		return ErrEntryTooLarge
+	}
+
+	c.stats.AddSets(1)
+	n, ok := c.nodeIndex[key]
+	if ok {
+		reason := cache.EvictReplaced
+		if c.nodeIsExpired(n, now) {
+			reason = cache.EvictExpired
+		}
+		c.evicts.push(key, n.value, reason)
+		c.bytes += n.setValue(value)
+		n.ttl = o.TTL
+		n.lastVisit = now
+		c.cost += cost - n.cost
+		n.cost = cost
+		c.touch(n)
+		c.evict(n)
+		return nil
+	}
+
+	if c.Cap > 0 && len(c.nodeIndex) >= c.Cap {
+		c.evictOne()
+	}
+	n = &lfuNode{node: node{key: key, lastVisit: now, ttl: o.TTL, cost: cost}}
+	c.nodeIndex[key] = n
+	c.cost += cost
+	c.bytes += n.setValue(value)
+	c.link(n, 1, nil)
+	c.evict(n)
+
+	return nil
+}
+
+// evict removes the least frequently used nodes other than n until it's within MaxCost.
+func (c *lfuCache) evict(n *lfuNode) {
+	for c.MaxCost > 0 && c.cost > c.MaxCost {
+		victim := c.buckets.Front().Value.(*lfuBucket).nodes.Front().Value.(*lfuNode)
+		if victim == n {
+			// skip n which is just set
+			if next := n.el.Next(); next != nil {
+				victim = next.Value.(*lfuNode)
+			} else {
+				victim = n.bucket.Next().Value.(*lfuBucket).nodes.Front().Value.(*lfuNode)
+			}
+		}
+		c.del(victim.key, cache.EvictCapacity)
+		c.stats.AddEvictions(1)
+	}
+}
+
+// evictOne removes the least frequently used node.
+func (c *lfuCache) evictOne() {
+	be := c.buckets.Front()
+	if be == nil {
+		return
+	}
+	n := be.Value.(*lfuBucket).nodes.Front().Value.(*lfuNode)
+	c.del(n.key, cache.EvictCapacity)
+	c.stats.AddEvictions(1)
+}
+
+// decay halves the frequencies of all nodes if DecayInterval passed since the last decay.
+// It's checked by get, set and GC, so a read-mostly cache decays as well.
+// Halving keeps the order of buckets, so buckets mapped to the same frequency are merged in place.
+func (c *lfuCache) decay(now time.Time) {
+	if c.DecayInterval <= 0 || now.Sub(c.lastDecay) < c.DecayInterval {
+		return
+	}
+	c.lastDecay = now
+
+	for be := c.buckets.Front(); be != nil; {
+		next := be.Next()
+		b := be.Value.(*lfuBucket)
+		b.freq /= 2
+		if b.freq < 1 {
+			b.freq = 1
+		}
+		if prev := be.Prev(); prev != nil && prev.Value.(*lfuBucket).freq == b.freq {
+			pb := prev.Value.(*lfuBucket)
+			for e := b.nodes.Front(); e != nil; e = e.Next() {
+				n := e.Value.(*lfuNode)
+				n.bucket = prev
+				n.el = pb.nodes.PushBack(n)
+			}
+			c.buckets.Remove(be)
+		}
+		be = next
+	}
+}
@@ -0,0 +1,305 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LFUCacheGet(t *testing.T) {
+	n := 10
+	c := NewLFUCache(n)
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			c.Set(fmt.Sprintf("%d", i), i, cache.WithTTL(time.Second))
+		} else {
+			c.Set(fmt.Sprintf("%d", i), i)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		assert.NoError(t, err)
+		ret := v.(int)
+		assert.Equal(t, i, ret)
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		if i == 0 {
+			assert.Equal(t, cache.ErrNotFound, err)
+		} else {
+			assert.NoError(t, err)
+			ret := v.(int)
+			assert.Equal(t, i, ret)
+		}
+	}
+}
+
+func Test_LFUCacheEvict(t *testing.T) {
+	n := 10
+	c := NewLFUCache(n)
+	for i := 0; i < n; i++ {
+		c.Set(i, i)
+	}
+	// the hot set
+	for i := 0; i < n/2; i++ {
+		for j := 0; j < 3; j++ {
+			_, err := c.Get(i)
+			assert.NoError(t, err)
+		}
+	}
+
+	// a scan doesn't flush the hot set out
+	for i := n; i < n*10; i++ {
+		c.Set(i, i)
+	}
+	for i := 0; i < n/2; i++ {
+		ok, _ := c.Exists(i)
+		assert.True(t, ok, i)
+	}
+	for i := n / 2; i < n; i++ {
+		ok, _ := c.Exists(i)
+		assert.False(t, ok, i)
+	}
+	ok, _ := c.Exists(n*10 - 1)
+	assert.True(t, ok)
+	assert.Equal(t, n, len(c.nodeIndex))
+}
+
+func Test_LFUCacheDecay(t *testing.T) {
+	n := 4
+	c := NewLFUCacheWithConfig(n, LFUCacheConfig{
+		TTL:           time.Hour,
+		DecayInterval: time.Second,
+	})
+	for i := 0; i < n; i++ {
+		c.Set(i, i)
+		for j := 0; j < i*4; j++ {
+			c.Get(i)
+		}
+	}
+	freqs := func() []int {
+		ret := make([]int, n)
+		for i := 0; i < n; i++ {
+			ret[i] = c.nodeIndex[i].bucket.Value.(*lfuBucket).freq
+		}
+		return ret
+	}
+	assert.Equal(t, []int{1, 5, 9, 13}, freqs())
+
+	time.Sleep(1100 * time.Millisecond) // wait for decay
+	c.Set(0, 0)                         // decay happens before setting
+
+	assert.Equal(t, []int{2, 2, 4, 6}, freqs())
+	assert.Equal(t, 3, c.buckets.Len())
+}
+
+func Test_LFUCacheDecayOnGet(t *testing.T) {
+	c := NewLFUCacheWithConfig(2, LFUCacheConfig{
+		TTL:           time.Hour,
+		DecayInterval: time.Second,
+	})
+	c.Set(0, 0)
+	c.Set(1, 1)
+	for j := 0; j < 8; j++ {
+		c.Get(1)
+	}
+	assert.Equal(t, 9, c.nodeIndex[1].bucket.Value.(*lfuBucket).freq)
+
+	time.Sleep(1100 * time.Millisecond) // wait for decay
+	c.Get(0)                            // a read-only workload decays too
+
+	assert.Equal(t, 2, c.nodeIndex[0].bucket.Value.(*lfuBucket).freq)
+	assert.Equal(t, 4, c.nodeIndex[1].bucket.Value.(*lfuBucket).freq)
+
+	time.Sleep(1100 * time.Millisecond)
+	c.GC()
+
+	assert.Equal(t, 1, c.nodeIndex[0].bucket.Value.(*lfuBucket).freq)
+	assert.Equal(t, 2, c.nodeIndex[1].bucket.Value.(*lfuBucket).freq)
+}
+
+func Test_LFUCacheDelete(t *testing.T) {
+	n := 10
+	c := NewLFUCache(n)
+	for i := 0; i < n; i++ {
+		c.Set(i, fmt.Sprintf("%d", i))
+	}
+
+	for i := 0; i < n/2; i++ {
+		err := c.Delete(i)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < n; i++ {
+		ok, err := c.Exists(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i >= n/2, ok)
+	}
+
+	assert.NoError(t, c.Clear())
+	for i := 0; i < n; i++ {
+		ok, err := c.Exists(i)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+}
+
+func Test_LFUCacheGC(t *testing.T) {
+	n := 10
+	c := NewLFUCacheWithConfig(n, LFUCacheConfig{
+		TTL:        time.Hour,
+		GCOnceSize: n,
+	})
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			c.Set(i, i, cache.WithTTL(time.Second))
+		} else {
+			c.Set(i, i)
+		}
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+	c.GC()
+
+	assert.Equal(t, n/2, len(c.nodeIndex))
+}
+
+func Test_LFUCacheMaxCost(t *testing.T) {
+	c := NewLFUCacheWithConfig(0, LFUCacheConfig{
+		TTL:     time.Hour,
+		MaxCost: 100,
+	})
+
+	n := 10
+	for i := 0; i < n; i++ {
+		c.Set(i, i, cache.WithCost(20))
+		if i < 3 { // the hot set
+			c.Get(i)
+		}
+	}
+	assert.Equal(t, int64(100), c.cost)
+	for i := 0; i < 3; i++ {
+		ok, _ := c.Exists(i)
+		assert.True(t, ok, i)
+	}
+	ok, _ := c.Exists(n - 1) // the one just set
+	assert.True(t, ok)
+
+	// too big to be kept, and the others are not flushed for it
+	assert.Equal(t, ErrEntryTooLarge, c.Set("huge", "value", cache.WithCost(200)))
+	ok, _ = c.Exists("huge")
+	assert.False(t, ok)
+	assert.Equal(t, int64(100), c.cost)
+
+	// the value stored is kept
+	assert.Equal(t, ErrEntryTooLarge, c.Set(0, 1, cache.WithCost(200)))
+	v, err := c.Get(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+	assert.Equal(t, int64(100), c.cost)
+}
+
+func Test_LFUCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLFUCacheWithConfig(10, LFUCacheConfig{
+		TTL:           time.Minute,
+		GCInterval:    time.Minute,
+		GCOnceSize:    10,
+		DecayInterval: 30 * time.Second,
+		Clock:         clock,
+	})
+	defer c.Close(context.Background())
+
+	c.Set("default", 1)
+	c.Set("short", 2, cache.WithTTL(time.Second))
+	for j := 0; j < 3; j++ {
+		c.Get("default")
+	}
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 5, c.nodeIndex["default"].bucket.Value.(*lfuBucket).freq)
+
+	// the decay follows the clock, frequency 5 is halved before the visit
+	clock.Advance(30 * time.Second)
+	c.Get("default")
+	assert.Equal(t, 3, c.nodeIndex["default"].bucket.Value.(*lfuBucket).freq)
+
+	// the background GC is scheduled by the clock
+	clock.Advance(2 * time.Minute)
+	assert.Eventually(t, func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return len(c.nodeIndex) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_LFUCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLFUCacheWithConfig(2, LFUCacheConfig{TTL: time.Hour, Clock: clock})
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Get("b")
+	c.Set("c", 3) // evicts a
+	c.Get("a")
+	c.MGet([]interface{}{"b", "c"})
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries:   2,
+		Expired:   -1, // unknown
+		Bytes:     18,
+		Hits:      3,
+		Misses:    1,
+		Evictions: 1,
+		Sets:      3,
+	}, s)
+
+	c.Get("b")
+	c.Set("c", "12345")
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Expirations)
+	assert.Equal(t, int64(6), s.Bytes)
+	assert.NoError(t, c.Delete("c"))
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Deletes)
+	assert.Equal(t, int64(0), s.Entries)
+	assert.Equal(t, int64(0), s.Bytes)
+}
+
+func Test_LFUCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *lfuCache
+	var evicted []string
+	c = NewLFUCacheWithConfig(2, LFUCacheConfig{TTL: time.Hour, Clock: clock, OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%v=%v %v", key, value, reason))
+		c.Exists(key) // the cache isn't locked
+	}})
+
+	c.Set("a", 1)
+	c.Set("a", 2)
+	c.Set("b", 1, cache.WithTTL(time.Second))
+	c.Get("b")
+	c.Set("c", 1) // evicts a, the least frequently used
+	clock.Advance(time.Minute)
+	c.Get("b")
+	c.Delete("c")
+	c.Set("d", 1)
+	c.Clear()
+	assert.Equal(t, []string{"a=1 replaced", "a=2 capacity", "b=1 expired", "c=1 deleted", "d=1 cleared"}, evicted)
+}
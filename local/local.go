@@ -2,10 +2,12 @@ package local
 
 import (
 	"container/heap"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
 )
 
 var (
@@ -16,18 +18,46 @@ var (
 )
 
 var _ cache.Cache = (*localCache)(nil)
+var _ cache.StaleGetter = (*localCache)(nil)
+var _ cache.Sweepable = (*localCache)(nil)
+var _ cache.Closer = (*localCache)(nil)
+var _ cache.Expirer = (*localCache)(nil)
+var _ cache.Counter = (*localCache)(nil)
+var _ cache.ConditionalSetter = (*localCache)(nil)
+var _ cache.Iterator = (*localCache)(nil)
+var _ cache.StatsReporter = (*localCache)(nil)
 
 type LocalCacheConfig struct {
 	GCInterval time.Duration
 	GCOnceSize int
+	// TimingWheelTick enables the timing wheel expiration with the tick, instead of the expire heap
+	// and GCInterval. Expired entries are removed within about one tick after their deadlines.
+	TimingWheelTick time.Duration
+	// Sweeper enables the adaptive sweeper instead of GCInterval and GCOnceSize, if not nil.
+	Sweeper *cache.SweeperConfig
+	// Clock tells the time for expiration and schedules GC, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of the cache.
+	OnEvict cache.EvictFunc
 }
 
 type localCache struct {
 	LocalCacheConfig
 	mu sync.Mutex
 	m  map[interface{}]interface{}
-	e  map[interface{}]*expireNode
-	eh *expireHeap
+	// bytes is the estimated bytes of the keys and values of m, kept by put and remove.
+	bytes int64
+	e     map[interface{}]*expireNode
+	eh    *expireHeap
+	tw    *timingWheel
+	// v holds the versions of values for CompareAndSwap, taken from version which increases on every write.
+	v       map[interface{}]uint64
+	version uint64
+
+	sweeper *cache.Sweeper
+	lc      *lifecycle.Lifecycle
+	stats   cache.StatsCounter
+	evicts  evictQueue
 }
 
 var NewCache = NewLocalCache
@@ -42,95 +72,540 @@ func NewLocalCacheWithConfig(cfg LocalCacheConfig) *localCache {
 		m:                make(map[interface{}]interface{}),
 		e:                make(map[interface{}]*expireNode),
 		eh:               &expireHeap{},
+		v:                make(map[interface{}]uint64),
+		lc:               lifecycle.New(),
+		evicts:           evictQueue{fn: cfg.OnEvict},
 	}
 	heap.Init(c.eh)
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
 
-	if c.GCInterval > 0 { // Not run gc if equal 0
-		go c.runGC()
+	if c.TimingWheelTick > 0 {
+		c.tw = newTimingWheel(c.TimingWheelTick, c.Clock.Now())
+		c.lc.Every(c.Clock, c.TimingWheelTick, c.advance)
+	} else if c.Sweeper != nil {
+		c.sweeper = cache.NewSweeper(&c, *c.Sweeper)
+		c.lc.Every(c.Clock, c.sweeper.Interval, c.sweeper.Sweep)
+	} else { // Not run gc if GCInterval equals 0
+		c.lc.Every(c.Clock, c.GCInterval, c.gc)
 	}
 	return &c
 }
 
-func (c *localCache) runGC() {
-	tick := time.NewTicker(c.GCInterval)
-	defer func() {
-		tick.Stop()
-	}()
+// Close stops the background expiration and waits for it until ctx is done.
+func (c *localCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
+func (c *localCache) lock() {
+	c.mu.Lock()
+}
+
+// unlock unlocks the cache, and then calls OnEvict with the entries left while it's locked.
+func (c *localCache) unlock() {
+	queue := c.evicts.take()
+	c.mu.Unlock()
+	c.evicts.call(queue)
+}
 
-	for {
-		select {
-		case <-tick.C:
-			c.gc()
+// advance turns the timing wheel to now, and removes the keys expired.
+func (c *localCache) advance() {
+	c.lock()
+	defer c.unlock()
+
+	c.tw.advance(c.Clock.Now(), func(t *wheelTimer) {
+		c.evicts.push(t.key, c.m[t.key], cache.EvictExpired)
+		c.remove(t.key)
+		delete(c.e, t.key)
+		delete(c.v, t.key)
+		c.stats.AddExpirations(1)
+	})
+	c.stats.AddGCRuns(1)
+}
+
+func (c *localCache) gc() {
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	for size := 0; size < c.GCOnceSize && c.eh.Len() > 0; size++ {
+		n := (*c.eh)[0]
+		if !n.isExpired(now) {
+			break
 		}
+		c.reclaim(n)
 	}
+	c.stats.AddGCRuns(1)
 }
 
-func (c *localCache) gc() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// SweepSample removes the expired ones of at most n keys with ttl, sampled by the random order of map iteration.
+func (c *localCache) SweepSample(n int) (int, int, error) {
+	c.lock()
+	defer c.unlock()
 
-	size := 0
-	for {
-		n := c.eh.Pop().(*expireNode)
-		if n == nil || !n.isExpired(time.Now()) || size > c.GCOnceSize {
+	sampled, removed := 0, 0
+	now := c.Clock.Now()
+	for _, node := range c.e {
+		if sampled >= n {
 			break
 		}
-		c.delNode(n)
-		size++
+		sampled++
+		if node.isExpired(now) {
+			c.reclaim(node)
+			removed++
+		}
+	}
+	c.stats.AddGCRuns(1)
+	return sampled, removed, nil
+}
+
+// SweeperStats returns the counters of the sweeper, zero if it's not enabled.
+func (c *localCache) SweeperStats() cache.SweeperStats {
+	if c.sweeper == nil {
+		return cache.SweeperStats{}
+	}
+	return c.sweeper.Stats()
+}
+
+// put stores value of key into m, and updates the estimated bytes.
+func (c *localCache) put(key, value interface{}) {
+	if old, ok := c.m[key]; ok {
+		c.bytes -= cache.EstimateSize(key) + cache.EstimateSize(old)
+	}
+	c.m[key] = value
+	c.bytes += cache.EstimateSize(key) + cache.EstimateSize(value)
+}
+
+// remove deletes key from m, and updates the estimated bytes.
+func (c *localCache) remove(key interface{}) {
+	if old, ok := c.m[key]; ok {
+		c.bytes -= cache.EstimateSize(key) + cache.EstimateSize(old)
+		delete(c.m, key)
 	}
 }
 
 func (c *localCache) delNode(n *expireNode) {
-	delete(c.m, n.key)
+	c.remove(n.key)
+	delete(c.v, n.key)
+	c.unschedule(n)
+}
+
+// reclaim removes the expired node n.
+func (c *localCache) reclaim(n *expireNode) {
+	c.evicts.push(n.key, c.m[n.key], cache.EvictExpired)
+	c.delNode(n)
+	c.stats.AddExpirations(1)
+}
+
+// schedule adds or updates the expiration of n.
+func (c *localCache) schedule(n *expireNode, expireAt time.Time) {
+	if c.tw != nil {
+		n.expireAt = expireAt
+		n.timer.key = n.key
+		c.tw.schedule(&n.timer, expireAt)
+		return
+	}
+	if n.index < 0 {
+		n.expireAt = expireAt
+		heap.Push(c.eh, n)
+		return
+	}
+	c.eh.update(n, expireAt)
+}
+
+// unschedule removes the expiration of n.
+func (c *localCache) unschedule(n *expireNode) {
 	delete(c.e, n.key)
-	heap.Remove(c.eh, n.index)
+	c.cancel(n)
+}
+
+// cancel stops the expiration of n, which is kept in c.e with the ttl it was set with.
+func (c *localCache) cancel(n *expireNode) {
+	n.expireAt = time.Time{}
+	if c.tw != nil {
+		c.tw.cancel(&n.timer)
+		return
+	}
+	if n.index >= 0 {
+		heap.Remove(c.eh, n.index)
+	}
+}
+
+// alive returns the expire node of key, nil if it has no ttl, and false if key is missing or expired.
+func (c *localCache) alive(key interface{}, now time.Time) (*expireNode, bool) {
+	if _, ok := c.m[key]; !ok {
+		return nil, false
+	}
+	n, ok := c.e[key]
+	if !ok {
+		return nil, true
+	}
+	if n.isExpired(now) {
+		c.reclaim(n)
+		return nil, false
+	}
+	return n, true
+}
+
+func (c *localCache) TTL(key interface{}, options ...cache.Option) (time.Duration, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return 0, cache.ErrNotFound
+	}
+	if n == nil || n.expireAt.IsZero() {
+		return 0, nil
+	}
+	return n.expireAt.Sub(now), nil
+}
+
+func (c *localCache) Expire(key interface{}, ttl time.Duration, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return cache.ErrNotFound
+	}
+	if ttl <= 0 {
+		c.evicts.push(key, c.m[key], cache.EvictDeleted)
+		if n != nil {
+			c.delNode(n)
+		} else {
+			c.remove(key)
+			delete(c.v, key)
+		}
+		c.stats.AddDeletes(1)
+		return nil
+	}
+	if n == nil { // key was set without ttl, Touch renews with this one
+		n = &expireNode{key: key, index: -1, ttl: ttl}
+		c.e[key] = n
+	}
+	c.schedule(n, now.Add(ttl))
+	return nil
+}
+
+func (c *localCache) Persist(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.alive(key, c.Clock.Now())
+	if !ok {
+		return cache.ErrNotFound
+	}
+	if n != nil {
+		n.softExpireAt = time.Time{}
+		c.cancel(n)
+	}
+	return nil
+}
+
+func (c *localCache) Touch(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return cache.ErrNotFound
+	}
+	ttl := o.HardTTL()
+	if ttl <= 0 && n != nil {
+		ttl = n.ttl
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	if n == nil {
+		n = &expireNode{key: key, index: -1, ttl: ttl}
+		c.e[key] = n
+	}
+	c.schedule(n, now.Add(ttl))
+	return nil
 }
 
 func (c *localCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
 
 	v, ok := c.m[key]
 	if !ok {
+		c.stats.Hit(false)
 		return nil, cache.ErrNotFound
 	}
 	node, ok := c.e[key]
-	if ok && node.isExpired(time.Now()) {
-		c.delNode(node)
+	if ok && node.isExpired(c.Clock.Now()) {
+		c.reclaim(node)
+		c.stats.Hit(false)
 		return nil, cache.ErrNotFound
 	}
+	c.stats.Hit(true)
 	return v, nil
 }
 
 func (c *localCache) Set(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lock()
+	defer c.unlock()
 
-	c.m[key] = value
-	if o.TTL > 0 {
-		expireAt := time.Now().Add(o.TTL)
-		n, ok := c.e[key]
-		if ok { // expire node exists
-			c.eh.update(n, expireAt)
-		} else {
-			n := &expireNode{
-				key:      key,
-				expireAt: expireAt,
-			}
-			c.e[key] = n
-			c.eh.Push(n)
+	c.set(key, value, &o, c.Clock.Now())
+	return nil
+}
+
+// GetStale is like Get, but also reports whether the entry is past its soft expiry.
+func (c *localCache) GetStale(key interface{}, options ...cache.Option) (interface{}, bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, false, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	v, ok := c.m[key]
+	if !ok {
+		c.stats.Hit(false)
+		return nil, false, cache.ErrNotFound
+	}
+	node, ok := c.e[key]
+	if !ok {
+		c.stats.Hit(true)
+		return v, false, nil
+	}
+	now := c.Clock.Now()
+	if node.isExpired(now) {
+		c.reclaim(node)
+		c.stats.Hit(false)
+		return nil, false, cache.ErrNotFound
+	}
+	c.stats.Hit(true)
+	return v, node.isStale(now), nil
+}
+
+func (c *localCache) set(key, value interface{}, o *cache.Options, now time.Time) {
+	c.stats.AddSets(1)
+	if old, ok := c.m[key]; ok {
+		reason := cache.EvictReplaced
+		if n, ok := c.e[key]; ok && n.isExpired(now) {
+			reason = cache.EvictExpired
+		}
+		c.evicts.push(key, old, reason)
+	}
+	c.put(key, value)
+	c.version++
+	c.v[key] = c.version
+	n, ok := c.e[key]
+	ttl := o.HardTTL()
+	if ttl <= 0 {
+		if ok { // the former value has ttl
+			c.unschedule(n)
 		}
+		return
 	}
 
+	expireAt := now.Add(ttl)
+	var softExpireAt time.Time
+	if softTTL := o.SoftTTL(); softTTL > 0 {
+		softExpireAt = now.Add(softTTL)
+	}
+	if !ok {
+		n = &expireNode{key: key, index: -1}
+		c.e[key] = n
+	}
+	n.ttl = ttl
+	n.softExpireAt = softExpireAt
+	c.schedule(n, expireAt)
+}
+
+func (c *localCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *localCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+func (c *localCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	v, err := c.update(key, &o, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrInt(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (c *localCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	v, err := c.update(key, &o, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrFloat(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (c *localCache) Add(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	if _, ok := c.alive(key, now); ok {
+		return cache.ErrExisted
+	}
+	c.set(key, value, &o, now)
 	return nil
 }
 
+func (c *localCache) Replace(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	if _, ok := c.alive(key, now); !ok {
+		return cache.ErrNotFound
+	}
+	c.set(key, value, &o, now)
+	return nil
+}
+
+func (c *localCache) GetWithVersion(key interface{}, options ...cache.Option) (interface{}, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	if _, ok := c.alive(key, c.Clock.Now()); !ok {
+		c.stats.Hit(false)
+		return nil, 0, cache.ErrNotFound
+	}
+	c.stats.Hit(true)
+	return c.m[key], c.v[key], nil
+}
+
+func (c *localCache) CompareAndSwap(key, value interface{}, version uint64, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	if _, ok := c.alive(key, now); !ok {
+		return cache.ErrNotFound
+	}
+	if c.v[key] != version {
+		return cache.ErrVersionMismatch
+	}
+	c.set(key, value, &o, now)
+	return nil
+}
+
+// update replaces the value of key with the one returned by fn, ok tells whether key is alive.
+// A key created is set with o, the expiry of an existing one is kept.
+func (c *localCache) update(key interface{}, o *cache.Options, fn func(v interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	_, ok := c.alive(key, now)
+	v, err := fn(c.m[key], ok)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		c.evicts.push(key, c.m[key], cache.EvictReplaced)
+		c.put(key, v)
+		c.version++
+		c.v[key] = c.version
+		c.stats.AddSets(1)
+	} else {
+		c.set(key, v, o, now)
+	}
+	return v, nil
+}
+
 func (c *localCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
 
 	ret := make(map[interface{}]interface{})
 	for _, key := range keys {
@@ -139,79 +614,100 @@ func (c *localCache) MGet(keys []interface{}, options ...cache.Option) (map[inte
 			continue
 		}
 		n, ok := c.e[key]
-		if ok && n.isExpired(time.Now()) {
-			c.delNode(n)
+		if ok && n.isExpired(c.Clock.Now()) {
+			c.reclaim(n)
 			continue
 		}
 		ret[key] = v
 	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
 
 	return ret, nil
 }
 
 func (c *localCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lock()
+	defer c.unlock()
 
+	now := c.Clock.Now()
 	for k, v := range keyValues {
-		c.m[k] = v
-		if o.TTL > 0 {
-			expireAt := time.Now().Add(o.TTL)
-			n, ok := c.e[k]
-			if ok {
-				c.eh.update(n, expireAt)
-			} else {
-				n := &expireNode{
-					key:      k,
-					expireAt: expireAt,
-				}
-				c.eh.Push(n)
-				c.e[k] = n
-			}
-		}
+		c.set(k, v, &o, now)
 	}
 
 	return nil
 }
 
 func (c *localCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	c.lock()
+	defer c.unlock()
 
 	_, ok := c.m[key]
 	if !ok {
 		return false, nil
 	}
 	n, ok := c.e[key]
-	if ok && n.isExpired(time.Now()) {
-		c.delNode(n)
+	if ok && n.isExpired(c.Clock.Now()) {
+		c.reclaim(n)
 		return false, nil
 	}
 	return true, nil
 }
 
 func (c *localCache) Delete(key interface{}, options ...cache.Option) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if v, ok := c.m[key]; ok {
+		c.stats.AddDeletes(1)
+		c.evicts.push(key, v, cache.EvictDeleted)
+	}
 	n, ok := c.e[key]
 	if ok { // expire node exists
 		c.delNode(n)
 	} else {
-		delete(c.m, key)
+		c.remove(key)
+		delete(c.v, key)
 	}
 	return nil
 }
 
 func (c *localCache) Clear(options ...cache.Option) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.stats.AddDeletes(len(c.m))
+	if c.OnEvict != nil {
+		for k, v := range c.m {
+			c.evicts.push(k, v, cache.EvictCleared)
+		}
+	}
 	c.m = make(map[interface{}]interface{})
+	c.bytes = 0
 	c.e = make(map[interface{}]*expireNode)
+	c.v = make(map[interface{}]uint64)
 	c.eh = &expireHeap{}
 	heap.Init(c.eh)
+	if c.tw != nil {
+		c.tw.clear()
+	}
 	return nil
 }
 
@@ -219,10 +715,67 @@ func (c *localCache) Codec() cache.Codec {
 	return nil
 }
 
+func (c *localCache) Keys(pattern string, options ...cache.Option) ([]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
+	}
+	return matchedKeys(c.entries(), pattern), nil
+}
+
+func (c *localCache) Range(fn func(key, value interface{}) bool, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+	rangeEntries(c.entries(), fn)
+	return nil
+}
+
+// Stats reports the entries and their estimated bytes kept on writes. The expired ones are counted
+// by visiting only them in the expire heap, and unknown with the timing wheel, which removes them within a tick.
+func (c *localCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.lock()
+	defer c.unlock()
+	stats.Entries = int64(len(c.m))
+	stats.Bytes = c.bytes
+	if c.tw != nil {
+		stats.Expired = -1
+	} else {
+		stats.Expired = int64(c.eh.countExpired(0, c.Clock.Now()))
+	}
+	return stats, nil
+}
+
+// entries snapshots the live entries, expired ones are left to the expiration.
+func (c *localCache) entries() []entry {
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	entries := make([]entry, 0, len(c.m))
+	for k, v := range c.m {
+		if n, ok := c.e[k]; ok && n.isExpired(now) {
+			continue
+		}
+		entries = append(entries, entry{key: k, value: v})
+	}
+	return entries
+}
+
 type expireNode struct {
-	key      interface{}
-	index    int
-	expireAt time.Time
+	key          interface{}
+	index        int
+	expireAt     time.Time
+	softExpireAt time.Time
+	ttl          time.Duration // the ttl set with, for Touch
+	timer        wheelTimer
 }
 
 func (n expireNode) isExpired(deadline time.Time) bool {
@@ -232,6 +785,10 @@ func (n expireNode) isExpired(deadline time.Time) bool {
 	return false
 }
 
+func (n expireNode) isStale(deadline time.Time) bool {
+	return !n.softExpireAt.IsZero() && deadline.After(n.softExpireAt)
+}
+
 // An ttlHeap is a min-heap of expires
 type expireHeap []*expireNode
 
@@ -243,6 +800,14 @@ func (h expireHeap) Swap(i, j int) {
 	h[j].index = j
 }
 
+// countExpired counts the expired nodes of the subtree at i, only the expired ones and their children are visited.
+func (h expireHeap) countExpired(i int, now time.Time) int {
+	if i >= len(h) || !h[i].isExpired(now) {
+		return 0
+	}
+	return 1 + h.countExpired(2*i+1, now) + h.countExpired(2*i+2, now)
+}
+
 func (h *expireHeap) Push(x interface{}) {
 	// Push and Pop use pointer receivers because they modify the slice's length,
 	// not just its contents.
@@ -1,6 +1,7 @@
 package local
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -148,3 +149,375 @@ func Test_LocalCacheClear(t *testing.T) {
 		assert.False(t, ok)
 	}
 }
+
+func Test_LocalCacheGetStale(t *testing.T) {
+	c := NewLocalCache()
+	c.Set("stale", 1, cache.WithTTL(time.Second), cache.WithStaleTTL(time.Minute))
+	c.Set("ahead", 2, cache.WithTTL(2*time.Second), cache.WithRefreshAhead(time.Second+500*time.Millisecond))
+	c.Set("fresh", 3, cache.WithTTL(time.Minute))
+
+	for _, key := range []string{"stale", "ahead", "fresh"} {
+		_, stale, err := c.GetStale(key)
+		assert.NoError(t, err)
+		assert.False(t, stale, key)
+	}
+
+	time.Sleep(1500 * time.Millisecond) // wait for soft expires
+
+	for _, key := range []string{"stale", "ahead", "fresh"} {
+		v, stale, err := c.GetStale(key)
+		assert.NoError(t, err)
+		assert.Equal(t, key != "fresh", stale, key)
+		assert.NotNil(t, v)
+	}
+
+	time.Sleep(time.Second) // wait for hard expires of "ahead"
+
+	_, _, err := c.GetStale("ahead")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("stale")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// reset without ttl
+	c.Set("fresh", 3)
+	_, stale, err := c.GetStale("fresh")
+	assert.NoError(t, err)
+	assert.False(t, stale)
+}
+
+func Test_LocalCacheTimingWheel(t *testing.T) {
+	c := NewLocalCacheWithConfig(LocalCacheConfig{TimingWheelTick: 10 * time.Millisecond})
+	n := 10000
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			c.Set(i, i, cache.WithTTL(100*time.Millisecond))
+		} else {
+			c.Set(i, i, cache.WithTTL(time.Hour))
+		}
+	}
+	c.Set(0, 0) // without ttl now
+
+	time.Sleep(300 * time.Millisecond)
+
+	// removed without touching them
+	c.mu.Lock()
+	assert.Len(t, c.m, n/2+1)
+	assert.Len(t, c.e, n/2)
+	assert.Equal(t, n/2, c.tw.count)
+	c.mu.Unlock()
+
+	v, err := c.Get(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+	_, err = c.Get(2)
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_LocalCacheGC(t *testing.T) {
+	c := NewLocalCacheWithConfig(LocalCacheConfig{GCOnceSize: 5})
+	c.gc() // empty heap
+	for i := 0; i < 10; i++ {
+		c.Set(i, i, cache.WithTTL(time.Millisecond))
+	}
+	c.Set("no ttl", 1)
+	time.Sleep(10 * time.Millisecond)
+
+	c.gc()
+	assert.Len(t, c.m, 6)
+	c.gc()
+	assert.Len(t, c.m, 1)
+	c.gc()
+	assert.Len(t, c.m, 1)
+}
+
+func Test_LocalCacheSweeper(t *testing.T) {
+	cfg := cache.DefaultSweeperConfig
+	cfg.Interval = 10 * time.Millisecond
+	c := NewLocalCacheWithConfig(LocalCacheConfig{Sweeper: &cfg})
+	n := 1000
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			c.Set(i, i, cache.WithTTL(time.Hour))
+		} else {
+			c.Set(i, i, cache.WithTTL(10*time.Millisecond))
+		}
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	// sweeping keeps on until at most 25% sampled are expired
+	c.mu.Lock()
+	assert.True(t, len(c.m) < n/10*2)
+	c.mu.Unlock()
+	stats := c.SweeperStats()
+	assert.True(t, stats.Removed > uint64(n/2))
+	assert.True(t, stats.Sampled >= stats.Removed)
+}
+
+func Test_LocalCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{GCInterval: time.Minute, GCOnceSize: 20, Clock: clock})
+	defer c.Close(context.Background())
+
+	c.Set("short", 1, cache.WithTTL(time.Second))
+	c.Set("long", 2, cache.WithTTL(time.Hour))
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("long")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	// gc scheduled by the clock
+	c.Set("short", 1, cache.WithTTL(time.Second))
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.m) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func Test_LocalCacheExpirer(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{Clock: clock})
+
+	c.Set("ttl", 1, cache.WithTTL(time.Minute))
+	c.Set("no ttl", 2)
+
+	ttl, err := c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	ttl, err = c.TTL("no ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = c.TTL("missing")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	// touch renews with the ttl set with
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, c.Touch("ttl"))
+	ttl, _ = c.TTL("ttl")
+	assert.Equal(t, time.Minute, ttl)
+	assert.NoError(t, c.Touch("no ttl"))
+	ttl, _ = c.TTL("no ttl")
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.NoError(t, c.Touch("no ttl", cache.WithTTL(time.Hour)))
+	ttl, _ = c.TTL("no ttl")
+	assert.Equal(t, time.Hour, ttl)
+
+	// expire and persist
+	assert.NoError(t, c.Expire("ttl", time.Second))
+	ttl, _ = c.TTL("ttl")
+	assert.Equal(t, time.Second, ttl)
+	assert.NoError(t, c.Persist("ttl"))
+	clock.Advance(2 * time.Hour)
+	ttl, err = c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = c.Get("no ttl")
+	assert.Equal(t, cache.ErrNotFound, err)
+	assert.Equal(t, cache.ErrNotFound, c.Touch("no ttl"))
+
+	// the ttl set with survives persist
+	assert.NoError(t, c.Touch("ttl"))
+	ttl, _ = c.TTL("ttl")
+	assert.Equal(t, time.Minute, ttl)
+
+	assert.NoError(t, c.Expire("ttl", 0))
+	ok, _ := c.Exists("ttl")
+	assert.False(t, ok)
+	assert.Equal(t, cache.ErrNotFound, c.Expire("ttl", time.Second))
+	assert.Equal(t, cache.ErrNotFound, c.Persist("ttl"))
+
+	// a key set without ttl is touched with the one of Expire
+	c.Set("expire", 3)
+	assert.NoError(t, c.Expire("expire", time.Minute))
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, c.Touch("expire"))
+	ttl, _ = c.TTL("expire")
+	assert.Equal(t, time.Minute, ttl)
+
+	// a persisted key doesn't go stale
+	c.Set("soft", 4, cache.WithTTL(time.Minute), cache.WithStaleTTL(time.Minute))
+	assert.NoError(t, c.Persist("soft"))
+	clock.Advance(2 * time.Minute)
+	_, stale, err := c.GetStale("soft")
+	assert.NoError(t, err)
+	assert.False(t, stale)
+}
+
+func Test_LocalCacheCounter(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{Clock: clock})
+
+	v, err := c.Incr("cnt", cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	v, err = c.IncrBy("cnt", 10, cache.WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), v)
+	v, err = c.Decr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), v)
+
+	// the ttl is set on create only
+	ttl, _ := c.TTL("cnt")
+	assert.Equal(t, time.Minute, ttl)
+	clock.Advance(2 * time.Minute)
+	v, err = c.Incr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	ttl, _ = c.TTL("cnt")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	c.Set("int", 5)
+	f, err := c.IncrByFloat("int", 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5.5, f)
+	_, err = c.Incr("int")
+	assert.Equal(t, cache.ErrTypeMismatch, err)
+
+	c.Set("str", "a")
+	_, err = c.Incr("str")
+	assert.Equal(t, cache.ErrTypeMismatch, err)
+	got, _ := c.Get("str")
+	assert.Equal(t, "a", got)
+}
+
+func Test_LocalCacheConditionalSet(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{Clock: clock})
+
+	assert.Equal(t, cache.ErrNotFound, c.Replace("a", 1))
+	assert.NoError(t, c.Add("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrExisted, c.Add("a", 2))
+	assert.NoError(t, c.Replace("a", 3))
+	v, _ := c.Get("a")
+	assert.Equal(t, 3, v)
+
+	v, ver, err := c.GetWithVersion("a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+	assert.NoError(t, c.CompareAndSwap("a", 4, ver))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+	_, ver, _ = c.GetWithVersion("a")
+	c.Incr("b")
+	c.Set("a", 4)
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+
+	// expired keys can be added
+	c.Set("a", 1, cache.WithTTL(time.Minute))
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, cache.ErrNotFound, c.CompareAndSwap("a", 5, ver))
+	assert.NoError(t, c.Add("a", 2))
+}
+
+func Test_LocalCacheIterator(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{Clock: clock})
+
+	c.Set("user:1", 1)
+	c.Set("user:2", 2, cache.WithTTL(time.Minute))
+	c.Set("order:1", 3)
+	keys, err := c.Keys("user:*")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []interface{}{"user:1", "user:2"}, keys)
+
+	// expired entries are skipped
+	clock.Advance(time.Hour)
+	keys, _ = c.Keys("")
+	assert.ElementsMatch(t, []interface{}{"user:1", "order:1"}, keys)
+
+	// the cache can be used in fn
+	m := make(map[interface{}]interface{})
+	assert.NoError(t, c.Range(func(key, value interface{}) bool {
+		m[key] = value
+		c.Delete(key)
+		return true
+	}))
+	assert.Equal(t, map[interface{}]interface{}{"user:1": 1, "order:1": 3}, m)
+	keys, _ = c.Keys("")
+	assert.Empty(t, keys)
+
+	_, err = c.Keys("[")
+	assert.Error(t, err)
+}
+
+func Test_LocalCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLocalCacheWithConfig(LocalCacheConfig{GCInterval: time.Minute, GCOnceSize: 1, Clock: clock})
+	defer c.Close(context.Background())
+
+	c.Set("a", "12345")
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Set("c", 3, cache.WithTTL(time.Second))
+	c.Get("a")
+	c.Get("x")
+	c.MGet([]interface{}{"a", "y"})
+	c.Delete("a")
+	c.Delete("a")
+
+	clock.Advance(time.Minute) // GC removes one of the expired
+	assert.Eventually(t, func() bool {
+		s, _ := c.Stats()
+		return s.GCRuns == 1
+	}, time.Second, time.Millisecond)
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries:     1,
+		Expired:     1,
+		Bytes:       9, // "b" or "c" and an int
+		Hits:        2,
+		Misses:      2,
+		Expirations: 1,
+		Sets:        3,
+		Deletes:     1,
+		GCRuns:      1,
+	}, s)
+
+	// bytes are kept on writes
+	c.Set("d", "123")
+	s, _ = c.Stats()
+	assert.Equal(t, int64(13), s.Bytes)
+	c.Set("d", "1")
+	s, _ = c.Stats()
+	assert.Equal(t, int64(11), s.Bytes)
+	c.Delete("d")
+	s, _ = c.Stats()
+	assert.Equal(t, int64(9), s.Bytes)
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, int64(0), s.Bytes)
+}
+
+func Test_LocalCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *localCache
+	evicted := make(map[interface{}]cache.EvictReason)
+	c = NewLocalCacheWithConfig(LocalCacheConfig{Clock: clock, OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+		evicted[key] = reason
+		c.Exists(key) // the cache isn't locked
+	}})
+
+	c.Set("replaced", 1)
+	c.Set("replaced", 2)
+	c.Set("expired", 1, cache.WithTTL(time.Second))
+	c.Set("deleted", 1)
+	c.Delete("deleted")
+	c.Set("cleared", 1)
+	clock.Advance(time.Minute)
+	c.Get("expired")
+	assert.Equal(t, map[interface{}]cache.EvictReason{
+		"replaced": cache.EvictReplaced,
+		"expired":  cache.EvictExpired,
+		"deleted":  cache.EvictDeleted,
+	}, evicted)
+
+	c.Clear()
+	assert.Equal(t, cache.EvictCleared, evicted["cleared"])
+	assert.Equal(t, cache.EvictCleared, evicted["replaced"])
+}
@@ -2,10 +2,12 @@ package local
 
 import (
 	"container/list"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
 )
 
 const (
@@ -24,9 +26,29 @@ type LRUCacheConfig struct {
 	TTL        time.Duration
 	GCInterval time.Duration
 	GCOnceSize int
+	// MaxCost bounds the total cost of entries besides Cap, not bound if equal 0.
+	MaxCost int64
+	// Cost computes the cost of an entry if not overridden by cache.WithCost(),
+	// cache.DefaultCostFunc(Encoder) is used if nil.
+	Cost cache.CostFunc
+	// Encoder sizes values by their encoded length for the default Cost, values are stored as is anyway.
+	Encoder cache.Encoder
+	// Sweeper enables the adaptive sweeper instead of GCInterval and GCOnceSize, if not nil.
+	Sweeper *cache.SweeperConfig
+	// Clock tells the time for expiration and schedules GC, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of the cache.
+	OnEvict cache.EvictFunc
 }
 
 var _ cache.Cache = (*lruCache)(nil)
+var _ cache.Closer = (*lruCache)(nil)
+var _ cache.Sweepable = (*lruCache)(nil)
+var _ cache.Expirer = (*lruCache)(nil)
+var _ cache.Counter = (*lruCache)(nil)
+var _ cache.ConditionalSetter = (*lruCache)(nil)
+var _ cache.Iterator = (*lruCache)(nil)
+var _ cache.StatsReporter = (*lruCache)(nil)
 
 type lruCache struct {
 	Cap int
@@ -34,7 +56,14 @@ type lruCache struct {
 
 	nodeList  *list.List
 	nodeIndex map[interface{}]*list.Element
+	cost      int64
+	bytes     int64  // estimated bytes of entries
+	version   uint64 // increases on every write, for CompareAndSwap
 	mutex     sync.Mutex
+	lc        *lifecycle.Lifecycle
+	sweeper   *cache.Sweeper
+	stats     cache.StatsCounter
+	evicts    evictQueue
 }
 
 func NewLRUCache(cap int) *lruCache {
@@ -47,41 +76,62 @@ func NewLRUCacheWithConfig(cap int, cfg LRUCacheConfig) *lruCache {
 		nodeIndex:      make(map[interface{}]*list.Element),
 		Cap:            cap,
 		LRUCacheConfig: cfg,
+		lc:             lifecycle.New(),
+		evicts:         evictQueue{fn: cfg.OnEvict},
+	}
+	if c.Cost == nil {
+		c.Cost = cache.DefaultCostFunc(c.Encoder)
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	if c.Sweeper != nil {
+		c.sweeper = cache.NewSweeper(c, *c.Sweeper)
+		c.lc.Every(c.Clock, c.sweeper.Interval, c.sweeper.Sweep)
+	} else {
+		c.lc.Every(c.Clock, c.GCInterval, c.GC)
 	}
-	c.runGC()
 	return c
 }
 
-func (c *lruCache) AdjustMaxCap(cap int) {
+func (c *lruCache) lock() {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+}
+
+// unlock unlocks the cache, and then calls OnEvict with the entries left while it's locked.
+func (c *lruCache) unlock() {
+	queue := c.evicts.take()
+	c.mutex.Unlock()
+	c.evicts.call(queue)
+}
+
+// Close stops the background GC and waits for it until ctx is done.
+func (c *lruCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
+func (c *lruCache) AdjustMaxCap(cap int) {
+	c.lock()
+	defer c.unlock()
 
 	c.Cap = cap
-	if c.Cap > 0 {
-		for {
-			if c.nodeList.Len() > c.Cap {
-				e := c.nodeList.Front()
-				n := e.Value.(*node)
-				c.del(n.key)
-			} else {
-				break
-			}
-		}
-	}
+	c.evict()
 }
 
-func (c *lruCache) runGC() {
-	if c.GCInterval > 0 {
-		time.AfterFunc(c.GCInterval, func() {
-			c.runGC()
-			c.GC()
-		})
+// evict removes the least recently used nodes until it's within Cap and MaxCost.
+func (c *lruCache) evict() {
+	for c.nodeList.Len() > 0 &&
+		((c.Cap > 0 && c.nodeList.Len() > c.Cap) || (c.MaxCost > 0 && c.cost > c.MaxCost)) {
+		e := c.nodeList.Front()
+		n := e.Value.(*node)
+		c.del(n.key, cache.EvictCapacity)
+		c.stats.AddEvictions(1)
 	}
 }
 
 func (c *lruCache) GC() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 
 	removedNum := 0
 	for e := c.nodeList.Front(); e != nil; {
@@ -89,35 +139,79 @@ func (c *lruCache) GC() {
 			//fmt.Printf("removing %d cache nodes ..., left %d\n", removedNum, m.idList.Len())
 			break
 		}
+		next := e.Next()
 		n := e.Value.(*node)
-		if c.nodeIsExpired(n, time.Now()) {
+		if c.nodeIsExpired(n, c.Clock.Now()) {
 			removedNum++
-			next := e.Next()
 			//fmt.Println("removing ...", e.Value)
-			c.del(n.key)
-			e = next
+			c.reclaim(n.key)
+		}
+		e = next
+	}
+	c.stats.AddGCRuns(1)
+}
+
+// SweepSample removes the expired ones of at most n keys, sampled by the random order of map iteration.
+func (c *lruCache) SweepSample(n int) (int, int, error) {
+	c.lock()
+	defer c.unlock()
+
+	sampled, removed := 0, 0
+	now := c.Clock.Now()
+	for key, e := range c.nodeIndex {
+		if sampled >= n {
+			break
 		}
+		sampled++
+		if c.nodeIsExpired(e.Value.(*node), now) {
+			c.reclaim(key)
+			removed++
+		}
+	}
+	c.stats.AddGCRuns(1)
+	return sampled, removed, nil
+}
+
+// SweeperStats returns the counters of the sweeper, zero if it's not enabled.
+func (c *lruCache) SweeperStats() cache.SweeperStats {
+	if c.sweeper == nil {
+		return cache.SweeperStats{}
 	}
+	return c.sweeper.Stats()
 }
 
 func (c *lruCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.get(key)
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+	v, err := c.get(key)
+	c.stats.Hit(err == nil)
+	return v, err
 }
 
 func (c *lruCache) Set(key interface{}, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	return c.set(key, value, &o)
 }
 
 func (c *lruCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
 
 	ret := make(map[interface{}]interface{})
 	for _, key := range keys {
@@ -127,15 +221,21 @@ func (c *lruCache) MGet(keys []interface{}, options ...cache.Option) (map[interf
 		}
 		ret[key] = v
 	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
 	return ret, nil
 }
 
 func (c *lruCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 
 	for k, v := range keyValues {
 		if err := c.set(k, v, &o); err != nil {
@@ -147,32 +247,310 @@ func (c *lruCache) MSet(keyValues map[interface{}]interface{}, options ...cache.
 }
 
 func (c *lruCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	c.lock()
+	defer c.unlock()
 
 	el, ok := c.nodeIndex[key]
 	if !ok {
 		return false, nil
 	}
 	n := el.Value.(*node)
-	if c.nodeIsExpired(n, time.Now()) {
-		c.del(key)
+	if c.nodeIsExpired(n, c.Clock.Now()) {
+		c.reclaim(key)
 		return false, nil
 	}
 	return true, nil
 }
 
+// TTL returns the time left before key expires if it's not visited.
+func (c *lruCache) TTL(key interface{}, options ...cache.Option) (time.Duration, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return 0, cache.ErrNotFound
+	}
+	if n.ttl < 0 {
+		return 0, nil
+	}
+	ttl := c.LRUCacheConfig.TTL
+	if n.ttl > 0 {
+		ttl = n.ttl
+	}
+	return ttl - now.Sub(n.lastVisit), nil
+}
+
+// Expire makes key expire after ttl from now, and ttl after its last visit from then on.
+func (c *lruCache) Expire(key interface{}, ttl time.Duration, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return cache.ErrNotFound
+	}
+	if ttl <= 0 {
+		c.stats.AddDeletes(1)
+		return c.del(key, cache.EvictDeleted)
+	}
+	n.ttl = ttl
+	n.lastVisit = now
+	return nil
+}
+
+func (c *lruCache) Persist(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.alive(key, c.Clock.Now())
+	if !ok {
+		return cache.ErrNotFound
+	}
+	n.ttl = -1
+	return nil
+}
+
+// Touch visits key without retrieving it, which renews its sliding expiry and makes it the most recently used.
+// cache.WithTTL() sets another ttl too.
+func (c *lruCache) Touch(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	if !ok {
+		return cache.ErrNotFound
+	}
+	if o.TTL > 0 {
+		n.ttl = o.TTL
+	}
+	n.lastVisit = now
+	c.nodeList.MoveToBack(c.nodeIndex[key])
+	return nil
+}
+
+// alive returns the node of key, false if key is missing or expired.
+func (c *lruCache) alive(key interface{}, now time.Time) (*node, bool) {
+	el, ok := c.nodeIndex[key]
+	if !ok {
+		return nil, false
+	}
+	n := el.Value.(*node)
+	if c.nodeIsExpired(n, now) {
+		c.reclaim(key)
+		return nil, false
+	}
+	return n, true
+}
+
+func (c *lruCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *lruCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+func (c *lruCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	v, err := c.update(key, &o, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrInt(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (c *lruCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	v, err := c.update(key, &o, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrFloat(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (c *lruCache) Add(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	if _, ok := c.alive(key, c.Clock.Now()); ok {
+		return cache.ErrExisted
+	}
+	return c.set(key, value, &o)
+}
+
+func (c *lruCache) Replace(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	if _, ok := c.alive(key, c.Clock.Now()); !ok {
+		return cache.ErrNotFound
+	}
+	return c.set(key, value, &o)
+}
+
+func (c *lruCache) GetWithVersion(key interface{}, options ...cache.Option) (interface{}, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	c.stats.Hit(ok)
+	if !ok {
+		return nil, 0, cache.ErrNotFound
+	}
+	n.lastVisit = now
+	c.nodeList.MoveToBack(c.nodeIndex[key])
+	return n.value, n.version, nil
+}
+
+func (c *lruCache) CompareAndSwap(key, value interface{}, version uint64, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.alive(key, c.Clock.Now())
+	if !ok {
+		return cache.ErrNotFound
+	}
+	if n.version != version {
+		return cache.ErrVersionMismatch
+	}
+	return c.set(key, value, &o)
+}
+
+// update replaces the value of key with the one returned by fn, ok tells whether key is alive.
+// A key created is set with o, an existing one is visited and keeps its ttl and cost.
+func (c *lruCache) update(key interface{}, o *cache.Options, fn func(v interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	n, ok := c.alive(key, now)
+	var old interface{}
+	if ok {
+		old = n.value
+	}
+	v, err := fn(old, ok)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return v, c.set(key, v, o)
+	}
+	c.evicts.push(key, n.value, cache.EvictReplaced)
+	c.bytes += n.setValue(v)
+	c.version++
+	n.version = c.version
+	n.lastVisit = now
+	c.nodeList.MoveToBack(c.nodeIndex[key])
+	c.stats.AddSets(1)
+	return v, nil
+}
+
 func (c *lruCache) Delete(key interface{}, options ...cache.Option) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.del(key)
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if _, ok := c.nodeIndex[key]; ok {
+		c.stats.AddDeletes(1)
+	}
+	return c.del(key, cache.EvictDeleted)
 }
 
 func (c *lruCache) Clear(options ...cache.Option) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.stats.AddDeletes(len(c.nodeIndex))
+	if c.OnEvict != nil {
+		for el := c.nodeList.Front(); el != nil; el = el.Next() {
+			n := el.Value.(*node)
+			c.evicts.push(n.key, n.value, cache.EvictCleared)
+		}
+	}
 	c.nodeIndex = make(map[interface{}]*list.Element)
 	c.nodeList = list.New()
+	c.cost = 0
+	c.bytes = 0
 	return nil
 }
 
@@ -180,16 +558,81 @@ func (c *lruCache) Codec() cache.Codec {
 	return nil
 }
 
-func (c *lruCache) del(key interface{}) error {
+func (c *lruCache) Keys(pattern string, options ...cache.Option) ([]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
+	}
+	return matchedKeys(c.entries(), pattern), nil
+}
+
+// Range visits entries from the least recently used one, the recency isn't changed by it.
+func (c *lruCache) Range(fn func(key, value interface{}) bool, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+	rangeEntries(c.entries(), fn)
+	return nil
+}
+
+// Stats reports the entries and their estimated bytes kept on writes.
+// Expired entries are unknown, as ttl slides from the last visit and isn't ordered by the lru list.
+func (c *lruCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.lock()
+	defer c.unlock()
+	stats.Entries = int64(len(c.nodeIndex))
+	stats.Expired = -1
+	stats.Bytes = c.bytes
+	return stats, nil
+}
+
+// entries snapshots the live entries from the least recently used one.
+func (c *lruCache) entries() []entry {
+	c.lock()
+	defer c.unlock()
+
+	now := c.Clock.Now()
+	entries := make([]entry, 0, len(c.nodeIndex))
+	for el := c.nodeList.Front(); el != nil; el = el.Next() {
+		n := el.Value.(*node)
+		if c.nodeIsExpired(n, now) {
+			continue
+		}
+		entries = append(entries, entry{key: n.key, value: n.value})
+	}
+	return entries
+}
+
+// del removes key for reason.
+func (c *lruCache) del(key interface{}, reason cache.EvictReason) error {
 	if el, ok := c.nodeIndex[key]; ok {
+		c.evicts.push(key, el.Value.(*node).value, reason)
+		c.cost -= el.Value.(*node).cost
+		c.bytes -= el.Value.(*node).size
 		c.nodeList.Remove(el)
 		delete(c.nodeIndex, key)
 	}
 	return nil
 }
 
+// reclaim removes the expired key.
+func (c *lruCache) reclaim(key interface{}) {
+	c.del(key, cache.EvictExpired)
+	c.stats.AddExpirations(1)
+}
+
 func (c *lruCache) nodeIsExpired(n *node, deadline time.Time) bool {
-	ttl := c.TTL
+	if n.ttl < 0 { // persisted
+		return false
+	}
+	ttl := c.LRUCacheConfig.TTL
 	if n.ttl > 0 {
 		ttl = n.ttl
 	}
@@ -206,32 +649,49 @@ func (c *lruCache) get(key interface{}) (interface{}, error) {
 	}
 
 	n := el.Value.(*node)
-	if c.nodeIsExpired(n, time.Now()) {
-		c.del(key)
+	if c.nodeIsExpired(n, c.Clock.Now()) {
+		c.reclaim(key)
 		return nil, cache.ErrNotFound
 	}
-	n.lastVisit = time.Now()
+	n.lastVisit = c.Clock.Now()
 	c.nodeList.MoveToBack(el)
 
 	return n.value, nil
 }
 
 func (c *lruCache) set(key, value interface{}, o *cache.Options) error {
+	cost := o.Cost
+	if cost <= 0 {
+		cost = c.Cost(key, value)
+	}
+	if c.MaxCost > 0 && cost > c.MaxCost { // never fits, not flush the others for it
+		return ErrEntryTooLarge
+	}
+
+	c.stats.AddSets(1)
 	el, ok := c.nodeIndex[key]
 	if !ok {
 		el = c.nodeList.PushBack(newNode(key, value, o.TTL))
 		c.nodeIndex[key] = el
 	}
 	n := el.Value.(*node)
-	n.value = value
+	if ok {
+		reason := cache.EvictReplaced
+		if c.nodeIsExpired(n, c.Clock.Now()) {
+			reason = cache.EvictExpired
+		}
+		c.evicts.push(key, n.value, reason)
+	}
+	c.bytes += n.setValue(value)
 	n.ttl = o.TTL
-	n.lastVisit = time.Now()
+	c.version++
+	n.version = c.version
+	n.lastVisit = c.Clock.Now()
+	c.cost += cost - n.cost
+	n.cost = cost
+	c.nodeList.MoveToBack(el)
 
-	if c.Cap > 0 && c.nodeList.Len() > c.Cap {
-		e := c.nodeList.Front()
-		n := e.Value.(*node)
-		c.del(n.key)
-	}
+	c.evict()
 
 	return nil
 }
@@ -241,8 +701,20 @@ type node struct {
 	value     interface{}
 	lastVisit time.Time
 	ttl       time.Duration
+	cost      int64
+	size      int64 // estimated bytes of key and value
+	version   uint64
+}
+
+// setValue sets the value of n, and returns the change of its estimated bytes.
+func (n *node) setValue(value interface{}) int64 {
+	n.value = value
+	size := cache.EstimateSize(n.key) + cache.EstimateSize(value)
+	delta := size - n.size
+	n.size = size
+	return delta
 }
 
 func newNode(key, value interface{}, ttl time.Duration) *node {
-	return &node{key, value, time.Now(), ttl}
+	return &node{key: key, value: value, lastVisit: time.Now(), ttl: ttl}
 }
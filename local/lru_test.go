@@ -1,11 +1,13 @@
 package local
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/codec/json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -157,3 +159,303 @@ func Test_LRUCacheClear(t *testing.T) {
 		assert.False(t, ok)
 	}
 }
+
+func Test_LRUCacheGC(t *testing.T) {
+	n := 10
+	c := NewLRUCacheWithConfig(n, LRUCacheConfig{
+		TTL:        time.Hour,
+		GCOnceSize: n,
+	})
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			c.Set(i, i)
+		} else {
+			c.Set(i, i, cache.WithTTL(time.Second))
+		}
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+	c.GC()                      // not blocked by the unexpired front node
+
+	assert.Equal(t, n/2, c.nodeList.Len())
+}
+
+type sizedValue []byte
+
+func (v sizedValue) Size() int64 {
+	return int64(len(v))
+}
+
+func Test_LRUCacheMaxCost(t *testing.T) {
+	c := NewLRUCacheWithConfig(0, LRUCacheConfig{
+		TTL:     time.Hour,
+		MaxCost: 100,
+	})
+
+	n := 10
+	for i := 0; i < n; i++ {
+		c.Set(i, make(sizedValue, 20))
+	}
+	assert.Equal(t, 5, c.nodeList.Len())
+	assert.Equal(t, int64(100), c.cost)
+	for i := 0; i < n; i++ {
+		ok, _ := c.Exists(i)
+		assert.Equal(t, i >= n/2, ok, i)
+	}
+
+	// override the cost
+	c.Set("big", "value", cache.WithCost(60))
+	assert.Equal(t, 3, c.nodeList.Len())
+	assert.Equal(t, int64(100), c.cost)
+
+	// too big to be kept, and the others are not flushed for it
+	assert.Equal(t, ErrEntryTooLarge, c.Set("huge", "value", cache.WithCost(200)))
+	ok, _ := c.Exists("huge")
+	assert.False(t, ok)
+	assert.Equal(t, 3, c.nodeList.Len())
+
+	// the value stored is kept, and not reported as replaced
+	var evicted []string
+	c.evicts.fn = func(key, value interface{}, reason cache.EvictReason) {
+		evicted = append(evicted, fmt.Sprint(key))
+	}
+	assert.Equal(t, ErrEntryTooLarge, c.Set("big", "other", cache.WithCost(200)))
+	v, err := c.Get("big")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+	assert.Equal(t, int64(100), c.cost)
+	assert.Empty(t, evicted)
+
+	assert.NoError(t, c.Delete("big"))
+	assert.NoError(t, c.Clear())
+	assert.Equal(t, int64(0), c.cost)
+}
+
+func Test_LRUCacheEncoderCost(t *testing.T) {
+	c := NewLRUCacheWithConfig(0, LRUCacheConfig{
+		TTL:     time.Hour,
+		MaxCost: 100,
+		Encoder: json.NewCodec(),
+	})
+
+	// the encoded size is the cost of values which aren't cache.Sizer
+	c.Set("key", "value")
+	assert.Equal(t, int64(len(`"value"`)), c.cost)
+	c.Set("sized", make(sizedValue, 20))
+	assert.Equal(t, int64(len(`"value"`)+20), c.cost)
+}
+
+func Test_LRUCacheSweeper(t *testing.T) {
+	cfg := cache.DefaultSweeperConfig
+	cfg.Interval = 10 * time.Millisecond
+	c := NewLRUCacheWithConfig(0, LRUCacheConfig{TTL: time.Hour, Sweeper: &cfg})
+	n := 1000
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			c.Set(i, i)
+		} else {
+			c.Set(i, i, cache.WithTTL(10*time.Millisecond))
+		}
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	c.mutex.Lock()
+	assert.True(t, c.nodeList.Len() < n/10*2)
+	c.mutex.Unlock()
+	assert.True(t, c.SweeperStats().Removed > uint64(n/2))
+}
+
+func Test_LRUCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLRUCacheWithConfig(10, LRUCacheConfig{TTL: time.Minute, Clock: clock})
+	defer c.Close(context.Background())
+
+	c.Set("default", 1)
+	c.Set("short", 2, cache.WithTTL(time.Second))
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// sliding ttl from the last visit
+	clock.Advance(50 * time.Second)
+	_, err = c.Get("default")
+	assert.NoError(t, err)
+	clock.Advance(50 * time.Second)
+	_, err = c.Get("default")
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	_, err = c.Get("default")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_LRUCacheExpirer(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLRUCacheWithConfig(2, LRUCacheConfig{TTL: time.Minute, Clock: clock})
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Hour))
+
+	ttl, err := c.TTL("a")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	clock.Advance(30 * time.Second)
+	ttl, _ = c.TTL("b")
+	assert.Equal(t, time.Hour-30*time.Second, ttl)
+
+	// touch renews the sliding expiry and the recency
+	assert.NoError(t, c.Touch("a"))
+	ttl, _ = c.TTL("a")
+	assert.Equal(t, time.Minute, ttl)
+	c.Set("c", 3)
+	ok, _ := c.Exists("b")
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Expire("a", time.Second))
+	clock.Advance(2 * time.Second)
+	_, err = c.TTL("a")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	assert.NoError(t, c.Persist("c"))
+	clock.Advance(time.Hour)
+	ttl, err = c.TTL("c")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	v, err := c.Get("c")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+}
+
+func Test_LRUCacheCounter(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLRUCacheWithConfig(2, LRUCacheConfig{TTL: time.Minute, Clock: clock})
+
+	v, err := c.IncrBy("a", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+	c.Set("b", 1)
+	v, err = c.Incr("a") // visits a
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+	c.Set("c", 1)
+	ok, _ := c.Exists("b")
+	assert.False(t, ok)
+
+	f, err := c.IncrByFloat("f", 1.5, cache.WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	clock.Advance(30 * time.Minute)
+	f, err = c.IncrByFloat("f", 1.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, f)
+	ttl, _ := c.TTL("f")
+	assert.Equal(t, time.Hour, ttl)
+}
+
+func Test_LRUCacheConditionalSet(t *testing.T) {
+	c := NewLRUCache(10)
+
+	assert.Equal(t, cache.ErrNotFound, c.Replace("a", 1))
+	assert.NoError(t, c.Add("a", 1))
+	assert.Equal(t, cache.ErrExisted, c.Add("a", 2))
+	assert.NoError(t, c.Replace("a", 3))
+
+	v, ver, err := c.GetWithVersion("a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+	c.Incr("a")
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+	v, ver, _ = c.GetWithVersion("a")
+	assert.Equal(t, int64(4), v)
+	assert.NoError(t, c.CompareAndSwap("a", 5, ver))
+	v, _ = c.Get("a")
+	assert.Equal(t, 5, v)
+}
+
+func Test_LRUCacheIterator(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLRUCacheWithConfig(10, LRUCacheConfig{TTL: time.Hour, Clock: clock})
+
+	c.Set(1, "a")
+	c.Set(2, "b", cache.WithTTL(time.Minute))
+	c.Set(3, "c")
+	c.Get(1)
+	clock.Advance(2 * time.Minute)
+
+	// from the least recently used one, the expired ones are skipped
+	var keys []interface{}
+	assert.NoError(t, c.Range(func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.Equal(t, []interface{}{3, 1}, keys)
+
+	// the recency isn't changed
+	keys, err := c.Keys("")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{3, 1}, keys)
+
+	keys, _ = c.Keys("1")
+	assert.Equal(t, []interface{}{1}, keys)
+}
+
+func Test_LRUCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewLRUCacheWithConfig(2, LRUCacheConfig{TTL: time.Hour, Clock: clock})
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Set("c", 3) // evicts a
+	c.Get("a")
+	c.Get("c")
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries:   2,
+		Expired:   -1, // unknown
+		Bytes:     18,
+		Hits:      1,
+		Misses:    1,
+		Evictions: 1,
+		Sets:      3,
+	}, s)
+
+	c.Get("b")
+	s, _ = c.Stats()
+	assert.Equal(t, int64(9), s.Bytes)
+	c.Set("c", "12345") // bytes follow the replaced value
+	s, _ = c.Stats()
+	assert.Equal(t, int64(6), s.Bytes)
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Expirations)
+	assert.Equal(t, uint64(1), s.Deletes)
+	assert.Equal(t, int64(0), s.Entries)
+}
+
+func Test_LRUCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *lruCache
+	var evicted []string
+	c = NewLRUCacheWithConfig(2, LRUCacheConfig{TTL: time.Hour, Clock: clock, OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%v=%v %v", key, value, reason))
+		c.Exists(key) // the cache isn't locked
+	}})
+
+	c.Set("a", 1)
+	c.Set("a", 2)
+	c.Set("b", 1, cache.WithTTL(time.Second))
+	c.Set("c", 1) // evicts a
+	clock.Advance(time.Minute)
+	c.Get("b")
+	c.Delete("c")
+	c.Set("d", 1)
+	c.Clear()
+	assert.Equal(t, []string{"a=1 replaced", "a=2 capacity", "b=1 expired", "c=1 deleted", "d=1 cleared"}, evicted)
+}
@@ -0,0 +1,411 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"reflect"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+)
+
+// DefaultShards is the shard count used when the one given is not positive.
+const DefaultShards = 16
+
+var _ cache.Cache = (*shardedCache)(nil)
+var _ cache.StaleGetter = (*shardedCache)(nil)
+var _ cache.Closer = (*shardedCache)(nil)
+var _ cache.Expirer = (*shardedCache)(nil)
+var _ cache.Counter = (*shardedCache)(nil)
+var _ cache.ConditionalSetter = (*shardedCache)(nil)
+var _ cache.Iterator = (*shardedCache)(nil)
+var _ cache.StatsReporter = (*shardedCache)(nil)
+
+// shardedCache spreads keys over shards by key hash, so operations on different shards don't contend for one lock.
+type shardedCache struct {
+	shards []cache.Cache
+}
+
+// NewShardedLocalCache creates a local cache with shards, each one has its own map and expire heap.
+func NewShardedLocalCache(shards int) *shardedCache {
+	return NewShardedLocalCacheWithConfig(shards, DefaultLocalCacheConfig)
+}
+
+func NewShardedLocalCacheWithConfig(shards int, cfg LocalCacheConfig) *shardedCache {
+	return newShardedCache(shards, func(int) cache.Cache {
+		return NewLocalCacheWithConfig(cfg)
+	})
+}
+
+// NewShardedLRUCache creates a lru cache with shards, each one has its own lru list.
+// cap and cfg.MaxCost are split across shards evenly, so the key evicted is the least recently used one of its shard.
+// The shard count is lowered to cap (or cfg.MaxCost) if it's smaller, so every shard has a limit.
+func NewShardedLRUCache(cap int, shards int) *shardedCache {
+	return NewShardedLRUCacheWithConfig(cap, shards, DefaultLRUCacheConfig)
+}
+
+func NewShardedLRUCacheWithConfig(cap int, shards int, cfg LRUCacheConfig) *shardedCache {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	if cap > 0 && cap < shards {
+		shards = cap
+	}
+	if cfg.MaxCost > 0 && cfg.MaxCost < int64(shards) {
+		shards = int(cfg.MaxCost)
+	}
+	maxCost := cfg.MaxCost
+	return newShardedCache(shards, func(i int) cache.Cache {
+		cfg := cfg
+		if maxCost > 0 {
+			cfg.MaxCost = shardCap(maxCost, shards, i)
+		}
+		return NewLRUCacheWithConfig(int(shardCap(int64(cap), shards, i)), cfg)
+	})
+}
+
+func newShardedCache(shards int, newShard func(i int) cache.Cache) *shardedCache {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	c := &shardedCache{
+		shards: make([]cache.Cache, shards),
+	}
+	for i := range c.shards {
+		c.shards[i] = newShard(i)
+	}
+	return c
+}
+
+// shardCap returns the part of cap for the i-th of n shards, the remainder goes to the first shards,
+// so the parts sum up to cap. 0 means no limit.
+func shardCap(cap int64, n int, i int) int64 {
+	if cap <= 0 {
+		return 0
+	}
+	part := cap / int64(n)
+	if int64(i) < cap%int64(n) {
+		part++
+	}
+	return part
+}
+
+func (c *shardedCache) shard(key interface{}) cache.Cache {
+	return c.shards[keyHash(key)%uint64(len(c.shards))]
+}
+
+func (c *shardedCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	return c.shard(key).Get(key, options...)
+}
+
+// GetStale is like Get, but also reports whether the entry is past its soft expiry.
+// Entries of shards which don't keep soft expiry are never stale.
+func (c *shardedCache) GetStale(key interface{}, options ...cache.Option) (interface{}, bool, error) {
+	s := c.shard(key)
+	if sg, ok := s.(cache.StaleGetter); ok {
+		return sg.GetStale(key, options...)
+	}
+	v, err := s.Get(key, options...)
+	return v, false, err
+}
+
+// expirer returns the shard of key as a cache.Expirer, cache.ErrUnsupported if it's not.
+func (c *shardedCache) expirer(key interface{}) (cache.Expirer, error) {
+	e, ok := c.shard(key).(cache.Expirer)
+	if !ok {
+		return nil, cache.ErrUnsupported
+	}
+	return e, nil
+}
+
+func (c *shardedCache) TTL(key interface{}, options ...cache.Option) (time.Duration, error) {
+	e, err := c.expirer(key)
+	if err != nil {
+		return 0, err
+	}
+	return e.TTL(key, options...)
+}
+
+func (c *shardedCache) Expire(key interface{}, ttl time.Duration, options ...cache.Option) error {
+	e, err := c.expirer(key)
+	if err != nil {
+		return err
+	}
+	return e.Expire(key, ttl, options...)
+}
+
+func (c *shardedCache) Persist(key interface{}, options ...cache.Option) error {
+	e, err := c.expirer(key)
+	if err != nil {
+		return err
+	}
+	return e.Persist(key, options...)
+}
+
+func (c *shardedCache) Touch(key interface{}, options ...cache.Option) error {
+	e, err := c.expirer(key)
+	if err != nil {
+		return err
+	}
+	return e.Touch(key, options...)
+}
+
+// counter returns the shard of key as a cache.Counter, cache.ErrUnsupported if it's not.
+func (c *shardedCache) counter(key interface{}) (cache.Counter, error) {
+	cnt, ok := c.shard(key).(cache.Counter)
+	if !ok {
+		return nil, cache.ErrUnsupported
+	}
+	return cnt, nil
+}
+
+func (c *shardedCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *shardedCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+func (c *shardedCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	cnt, err := c.counter(key)
+	if err != nil {
+		return 0, err
+	}
+	return cnt.IncrBy(key, delta, options...)
+}
+
+func (c *shardedCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	cnt, err := c.counter(key)
+	if err != nil {
+		return 0, err
+	}
+	return cnt.IncrByFloat(key, delta, options...)
+}
+
+// conditionalSetter returns the shard of key as a cache.ConditionalSetter, cache.ErrUnsupported if it's not.
+func (c *shardedCache) conditionalSetter(key interface{}) (cache.ConditionalSetter, error) {
+	cs, ok := c.shard(key).(cache.ConditionalSetter)
+	if !ok {
+		return nil, cache.ErrUnsupported
+	}
+	return cs, nil
+}
+
+func (c *shardedCache) Add(key, value interface{}, options ...cache.Option) error {
+	cs, err := c.conditionalSetter(key)
+	if err != nil {
+		return err
+	}
+	return cs.Add(key, value, options...)
+}
+
+func (c *shardedCache) Replace(key, value interface{}, options ...cache.Option) error {
+	cs, err := c.conditionalSetter(key)
+	if err != nil {
+		return err
+	}
+	return cs.Replace(key, value, options...)
+}
+
+func (c *shardedCache) GetWithVersion(key interface{}, options ...cache.Option) (interface{}, uint64, error) {
+	cs, err := c.conditionalSetter(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cs.GetWithVersion(key, options...)
+}
+
+func (c *shardedCache) CompareAndSwap(key, value interface{}, version uint64, options ...cache.Option) error {
+	cs, err := c.conditionalSetter(key)
+	if err != nil {
+		return err
+	}
+	return cs.CompareAndSwap(key, value, version, options...)
+}
+
+func (c *shardedCache) Set(key, value interface{}, options ...cache.Option) error {
+	return c.shard(key).Set(key, value, options...)
+}
+
+func (c *shardedCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	groups := make(map[cache.Cache][]interface{})
+	for _, key := range keys {
+		s := c.shard(key)
+		groups[s] = append(groups[s], key)
+	}
+
+	ret := make(map[interface{}]interface{})
+	for s, keys := range groups {
+		vals, err := s.MGet(keys, options...)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vals {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+func (c *shardedCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	groups := make(map[cache.Cache]map[interface{}]interface{})
+	for k, v := range keyValues {
+		s := c.shard(k)
+		if groups[s] == nil {
+			groups[s] = make(map[interface{}]interface{})
+		}
+		groups[s][k] = v
+	}
+
+	for s, kvs := range groups {
+		if err := s.MSet(kvs, options...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *shardedCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	return c.shard(key).Exists(key, options...)
+}
+
+func (c *shardedCache) Delete(key interface{}, options ...cache.Option) error {
+	return c.shard(key).Delete(key, options...)
+}
+
+func (c *shardedCache) Clear(options ...cache.Option) error {
+	for _, s := range c.shards {
+		if err := s.Clear(options...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keys returns the keys of all shards, cache.ErrUnsupported is returned if a shard isn't a cache.Iterator.
+func (c *shardedCache) Keys(pattern string, options ...cache.Option) ([]interface{}, error) {
+	var keys []interface{}
+	for _, shard := range c.shards {
+		it, ok := shard.(cache.Iterator)
+		if !ok {
+			return nil, cache.ErrUnsupported
+		}
+		ks, err := it.Keys(pattern, options...)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ks...)
+	}
+	return keys, nil
+}
+
+// Range visits the shards one by one, cache.ErrUnsupported is returned if a shard isn't a cache.Iterator.
+func (c *shardedCache) Range(fn func(key, value interface{}) bool, options ...cache.Option) error {
+	done := false
+	for _, shard := range c.shards {
+		it, ok := shard.(cache.Iterator)
+		if !ok {
+			return cache.ErrUnsupported
+		}
+		err := it.Range(func(key, value interface{}) bool {
+			if !fn(key, value) {
+				done = true
+			}
+			return !done
+		}, options...)
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats sums the stats of all shards, cache.ErrUnsupported is returned if a shard isn't a cache.StatsReporter.
+func (c *shardedCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	var stats cache.Stats
+	for _, shard := range c.shards {
+		r, ok := shard.(cache.StatsReporter)
+		if !ok {
+			return cache.Stats{}, cache.ErrUnsupported
+		}
+		s, err := r.Stats(options...)
+		if err != nil {
+			return cache.Stats{}, err
+		}
+		stats.Add(s)
+	}
+	return stats, nil
+}
+
+// Close closes all shards, and returns the first error of them.
+func (c *shardedCache) Close(ctx context.Context) error {
+	var ret error
+	for _, s := range c.shards {
+		if closer, ok := s.(cache.Closer); ok {
+			if err := closer.Close(ctx); err != nil && ret == nil {
+				ret = err
+			}
+		}
+	}
+	return ret
+}
+
+func (c *shardedCache) Codec() cache.Codec {
+	return nil
+}
+
+var hashSeed = maphash.MakeSeed()
+
+// keyHash hashes the key for choosing shards, keys equal as map keys must have the same hash.
+func keyHash(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return maphash.String(hashSeed, k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	case bool:
+		if k {
+			return 1
+		}
+		return 0
+	}
+	// pointers are equal as map keys by address, not by what they point to, which may change
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.Ptr, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return mix64(uint64(v.Pointer()))
+	}
+	// %#v keeps the type, so keys of different types are unlikely to collide
+	return maphash.String(hashSeed, fmt.Sprintf("%#v", key))
+}
+
+// mix64 is the finalizer of splitmix64, which spreads sequential integers over all bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,164 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShardedLocalCacheGet(t *testing.T) {
+	c := NewShardedLocalCache(4)
+	n := 100
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			c.Set(fmt.Sprintf("%d", i), i, cache.WithTTL(time.Second))
+		} else {
+			c.Set(fmt.Sprintf("%d", i), i)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		if i == 0 {
+			assert.Equal(t, cache.ErrNotFound, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, i, v)
+		}
+	}
+}
+
+func Test_ShardedLocalCacheMGet(t *testing.T) {
+	c := NewShardedLocalCache(4)
+	n := 100
+	kvs := make(map[interface{}]interface{})
+	keys := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			kvs[i] = i
+		}
+		keys = append(keys, i)
+	}
+	assert.NoError(t, c.MSet(kvs))
+
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, kvs, ret)
+
+	assert.NoError(t, c.Clear())
+	ret, err = c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ret))
+}
+
+func Test_ShardedCachePointerKey(t *testing.T) {
+	c := NewShardedLocalCache(64)
+	defer c.Close(context.Background())
+
+	// pointer keys are found by address, even if what they point to changes
+	type user struct{ name string }
+	keys := make([]*user, 32)
+	for i := range keys {
+		keys[i] = &user{name: fmt.Sprint(i)}
+		assert.NoError(t, c.Set(keys[i], i))
+	}
+	for i, key := range keys {
+		key.name = "renamed"
+		v, err := c.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+	_, err := c.Get(&user{name: "renamed"})
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_ShardedLRUCacheCap(t *testing.T) {
+	shards := 4
+	cap := 100
+	c := NewShardedLRUCache(cap, shards)
+	n := cap * 10
+	for i := 0; i < n; i++ {
+		c.Set(i, i)
+	}
+
+	count := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := c.Exists(i); ok {
+			count++
+		}
+	}
+	assert.Equal(t, cap, count)
+	for _, s := range c.shards {
+		assert.Equal(t, cap/shards, s.(*lruCache).nodeList.Len())
+	}
+}
+
+func Test_ShardedLRUCacheCapRemainder(t *testing.T) {
+	// the remainder is distributed, not rounded up on every shard
+	c := NewShardedLRUCache(100, 3)
+	caps := make([]int, 0, len(c.shards))
+	for _, s := range c.shards {
+		caps = append(caps, s.(*lruCache).Cap)
+	}
+	assert.Equal(t, []int{34, 33, 33}, caps)
+
+	// shards are lowered to cap, so none of them is unlimited
+	c = NewShardedLRUCache(2, 16)
+	assert.Equal(t, 2, len(c.shards))
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	count := 0
+	for i := 0; i < 100; i++ {
+		if ok, _ := c.Exists(i); ok {
+			count++
+		}
+	}
+	assert.LessOrEqual(t, count, 2)
+}
+
+func Test_ShardedLRUCacheConcurrent(t *testing.T) {
+	// room for all keys, so none is evicted between Set and Get
+	c := NewShardedLRUCache(16000, 8)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("%d-%d", g, i)
+				assert.NoError(t, c.Set(key, i))
+				v, err := c.Get(key)
+				assert.NoError(t, err)
+				assert.Equal(t, i, v)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func Test_ShardedCacheStats(t *testing.T) {
+	c := NewShardedLRUCache(100, 4)
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+		c.Get(i)
+	}
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), s.Entries)
+	assert.Equal(t, uint64(10), s.Hits)
+	assert.Equal(t, uint64(10), s.Sets)
+}
@@ -1,27 +1,46 @@
 package local
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"unsafe"
 
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
 )
 
 var _ cache.Cache = (*simpleCache)(nil)
+var _ cache.Closer = (*simpleCache)(nil)
+var _ cache.Counter = (*simpleCache)(nil)
+var _ cache.Iterator = (*simpleCache)(nil)
+var _ cache.StatsReporter = (*simpleCache)(nil)
 
 type simpleCache struct {
-	m *sync.Map
+	m     *sync.Map
+	lc    *lifecycle.Lifecycle
+	stats cache.StatsCounter
 }
 
 func NewSimpleCache() *simpleCache {
 	return &simpleCache{
-		m: new(sync.Map),
+		m:  new(sync.Map),
+		lc: lifecycle.New(),
 	}
 }
 
+// Close makes later operations return cache.ErrClosed.
+func (c *simpleCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
 func (c *simpleCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
 	v, ok := c.m.Load(key)
+	c.stats.Hit(ok)
 	if !ok {
 		return nil, cache.ErrNotFound
 	}
@@ -29,42 +48,189 @@ func (c *simpleCache) Get(key interface{}, options ...cache.Option) (interface{}
 }
 
 func (c *simpleCache) Set(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	c.m.Store(key, value)
+	c.stats.AddSets(1)
 	return nil
 }
 
 func (c *simpleCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
 	ret := make(map[interface{}]interface{})
 	for _, key := range keys {
 		if v, ok := c.m.Load(key); ok {
 			ret[key] = v
 		}
 	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
 	return ret, nil
 }
 
 func (c *simpleCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	for k, v := range keyValues {
 		c.m.Store(k, v)
 	}
+	c.stats.AddSets(len(keyValues))
 	return nil
 }
 
 func (c *simpleCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
 	_, ok := c.m.Load(key)
 	return ok, nil
 }
 
 func (c *simpleCache) Delete(key interface{}, options ...cache.Option) error {
-	c.m.Delete(key)
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := c.m.LoadAndDelete(key); ok {
+		c.stats.AddDeletes(1)
+	}
 	return nil
 }
 
 func (c *simpleCache) Clear(options ...cache.Option) error {
-	atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&c.m)), unsafe.Pointer(new(sync.Map)))
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	old := (*sync.Map)(atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&c.m)), unsafe.Pointer(new(sync.Map))))
+	c.stats.AddDeletes(mapLen(old))
+	return nil
+}
+
+func (c *simpleCache) Keys(pattern string, options ...cache.Option) ([]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
+	}
+
+	var keys []interface{}
+	c.m.Range(func(key, _ interface{}) bool {
+		if matchKey(pattern, key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys, nil
+}
+
+// Range is sync.Map.Range, which doesn't snapshot the entries but is safe to use the cache in fn.
+func (c *simpleCache) Range(fn func(key, value interface{}) bool, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.m.Range(fn)
 	return nil
 }
 
+func (c *simpleCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *simpleCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+func (c *simpleCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	v, err := c.update(key, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrInt(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (c *simpleCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	v, err := c.update(key, func(v interface{}, ok bool) (interface{}, error) {
+		if !ok {
+			return delta, nil
+		}
+		return incrFloat(v, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+// update replaces the value of key with the one returned by fn, ok tells whether key exists.
+// It retries until no one else changes the value in between, so fn may be called more than once.
+func (c *simpleCache) update(key interface{}, fn func(v interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	for {
+		m := c.m
+		old, ok := m.Load(key)
+		v, err := fn(old, ok)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if m.CompareAndSwap(key, old, v) {
+				c.stats.AddSets(1)
+				return v, nil
+			}
+		} else if _, loaded := m.LoadOrStore(key, v); !loaded {
+			c.stats.AddSets(1)
+			return v, nil
+		}
+	}
+}
+
 func (c *simpleCache) Codec() cache.Codec {
 	return nil
 }
+
+// Stats counts the entries and estimates their bytes by walking through them, nothing expires in the cache.
+func (c *simpleCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.m.Range(func(k, v interface{}) bool {
+		stats.Entries++
+		stats.Bytes += cache.EstimateSize(k) + cache.EstimateSize(v)
+		return true
+	})
+	return stats, nil
+}
+
+func mapLen(m *sync.Map) int {
+	n := 0
+	m.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
@@ -0,0 +1,35 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SimpleCacheStats(t *testing.T) {
+	c := NewSimpleCache()
+	c.Set("a", "123")
+	c.MSet(map[interface{}]interface{}{"b": 1, "c": 2})
+	c.Get("a")
+	c.Get("x")
+	c.Incr("cnt")
+	c.Delete("b")
+	c.Delete("b")
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries: 3,
+		Bytes:   3 + 1 + 1 + 8 + 3 + 8,
+		Hits:    1,
+		Misses:  1,
+		Sets:    4,
+		Deletes: 1,
+	}, s)
+
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(4), s.Deletes)
+	assert.Equal(t, int64(0), s.Entries)
+}
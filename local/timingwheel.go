@@ -0,0 +1,142 @@
+package local
+
+import (
+	"time"
+)
+
+const (
+	wheelBits   = 6
+	wheelSize   = 1 << wheelBits
+	wheelMask   = wheelSize - 1
+	wheelLevels = 8 // covers 2^48 ticks
+	wheelMax    = 1<<(wheelBits*wheelLevels) - 1
+)
+
+// wheelTimer is a node of the slot list it's scheduled in.
+type wheelTimer struct {
+	key        interface{}
+	at         int64 // deadline in ticks
+	prev, next *wheelTimer
+	slot       *wheelTimer // sentinel of the slot, nil if not scheduled
+}
+
+// timingWheel is a hierarchical timing wheel. Level i has wheelSize slots of wheelSize^i ticks each,
+// a timer is placed in the level matching its distance to the deadline, and moved down to lower levels
+// as the wheel turns, until it fires from level 0. Scheduling, canceling and firing are all O(1),
+// each timer is moved at most wheelLevels times.
+type timingWheel struct {
+	tick   time.Duration
+	start  time.Time
+	cur    int64 // ticks passed since start
+	count  int
+	levels [wheelLevels][wheelSize]wheelTimer
+}
+
+func newTimingWheel(tick time.Duration, now time.Time) *timingWheel {
+	tw := &timingWheel{
+		tick:  tick,
+		start: now,
+	}
+	tw.clear()
+	return tw
+}
+
+// clear drops all timers.
+func (tw *timingWheel) clear() {
+	for i := range tw.levels {
+		for j := range tw.levels[i] {
+			s := &tw.levels[i][j]
+			s.prev = s
+			s.next = s
+		}
+	}
+	tw.count = 0
+}
+
+// schedule (re)schedules t to fire at the first tick not before deadline.
+func (tw *timingWheel) schedule(t *wheelTimer, deadline time.Time) {
+	tw.cancel(t)
+	d := deadline.Sub(tw.start)
+	at := int64(d / tw.tick)
+	if d%tw.tick != 0 {
+		at++
+	}
+	if at <= tw.cur {
+		at = tw.cur + 1
+	}
+	t.at = at
+	tw.place(t)
+	tw.count++
+}
+
+func (tw *timingWheel) cancel(t *wheelTimer) {
+	if t.slot == nil {
+		return
+	}
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.prev, t.next, t.slot = nil, nil, nil
+	tw.count--
+}
+
+// place links t to the slot of its deadline, which is not before cur.
+func (tw *timingWheel) place(t *wheelTimer) {
+	at := t.at
+	delta := at - tw.cur
+	if delta > wheelMax {
+		at = tw.cur + wheelMax
+		delta = wheelMax
+	}
+	level := 0
+	for delta >= wheelSize && level < wheelLevels-1 {
+		delta >>= wheelBits
+		level++
+	}
+	s := &tw.levels[level][(at>>(wheelBits*level))&wheelMask]
+	t.slot = s
+	t.prev = s.prev
+	t.next = s
+	s.prev.next = t
+	s.prev = t
+}
+
+// advance turns the wheel to now, and calls fire for the timers passed their deadlines.
+// Fired timers are unscheduled before calling fire.
+func (tw *timingWheel) advance(now time.Time, fire func(t *wheelTimer)) {
+	target := int64(now.Sub(tw.start) / tw.tick)
+	for tw.cur < target {
+		if tw.count == 0 {
+			tw.cur = target
+			return
+		}
+		tw.cur++
+		tw.cascade()
+
+		s := &tw.levels[0][tw.cur&wheelMask]
+		for s.next != s {
+			t := s.next
+			tw.cancel(t)
+			fire(t)
+		}
+	}
+}
+
+// cascade moves the timers of the slots reached by cur at higher levels to lower ones, from the highest.
+func (tw *timingWheel) cascade() {
+	level := 0
+	for level < wheelLevels-1 && (tw.cur>>(wheelBits*(level+1)))<<(wheelBits*(level+1)) == tw.cur {
+		level++
+	}
+	for ; level > 0; level-- {
+		s := &tw.levels[level][(tw.cur>>(wheelBits*level))&wheelMask]
+		head := s.next
+		s.prev.next = nil
+		s.prev = s
+		s.next = s
+		for t := head; t != nil && t != s; {
+			next := t.next
+			tw.place(t)
+			t = next
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package local
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TimingWheel(t *testing.T) {
+	start := time.Now()
+	tw := newTimingWheel(time.Millisecond, start)
+
+	n := 10000
+	timers := make([]wheelTimer, n)
+	deadlines := make(map[int]time.Time)
+	for i := range timers {
+		timers[i].key = i
+		// spread over several levels
+		d := time.Duration(rand.Int63n(int64(10 * time.Minute)))
+		deadlines[i] = start.Add(d)
+		tw.schedule(&timers[i], deadlines[i])
+	}
+	// cancel and reschedule some of them
+	for i := 0; i < n; i += 10 {
+		tw.cancel(&timers[i])
+		delete(deadlines, i)
+	}
+	for i := 1; i < n; i += 10 {
+		deadlines[i] = deadlines[i].Add(time.Minute)
+		tw.schedule(&timers[i], deadlines[i])
+	}
+	assert.Equal(t, len(deadlines), tw.count)
+
+	now := start
+	for now.Before(start.Add(12 * time.Minute)) {
+		now = now.Add(time.Duration(rand.Int63n(int64(time.Second))))
+		tw.advance(now, func(wt *wheelTimer) {
+			i := wt.key.(int)
+			deadline, ok := deadlines[i]
+			if !assert.True(t, ok) {
+				return
+			}
+			assert.False(t, now.Before(deadline))
+			delete(deadlines, i)
+		})
+		for i, deadline := range deadlines {
+			if !assert.False(t, now.Sub(deadline) > time.Millisecond, i) {
+				return
+			}
+		}
+	}
+	assert.Len(t, deadlines, 0)
+	assert.Equal(t, 0, tw.count)
+}
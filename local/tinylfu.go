@@ -0,0 +1,498 @@
+package local
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
+)
+
+var DefaultTinyLFUCacheConfig = TinyLFUCacheConfig{
+	TTL:            time.Hour,
+	GCInterval:     10 * time.Minute,
+	GCOnceSize:     20,
+	WindowRatio:    0.01,
+	ProtectedRatio: 0.8,
+}
+
+type TinyLFUCacheConfig struct {
+	TTL        time.Duration
+	GCInterval time.Duration
+	GCOnceSize int
+	// WindowRatio is the ratio of the lru window to the capacity.
+	WindowRatio float64
+	// ProtectedRatio is the ratio of the protected segment to the main region.
+	ProtectedRatio float64
+	// Clock tells the time for expiration and schedules GC, cache.RealClock is used if nil.
+	Clock cache.Clock
+	// OnEvict is called when an entry leaves the cache, without holding the lock of the cache.
+	OnEvict cache.EvictFunc
+}
+
+var _ cache.Cache = (*tinyLFUCache)(nil)
+var _ cache.Closer = (*tinyLFUCache)(nil)
+var _ cache.StatsReporter = (*tinyLFUCache)(nil)
+
+const (
+	regionWindow = iota
+	regionProbation
+	regionProtected
+)
+
+// tinyLFUCache is a Window-TinyLFU cache.
+// New keys enter a small lru window. A key evicted from the window is admitted to the main region,
+// a segmented lru of probation and protected segments, only if it's estimated to be used more
+// frequently than the victim of the main region. Frequencies are estimated by a count-min sketch,
+// which is halved periodically so it keeps up with the changing workload.
+// Entries are bounded by count only, Set with cache.WithCost() returns cache.ErrUnsupported.
+type tinyLFUCache struct {
+	Cap int
+	TinyLFUCacheConfig
+
+	windowCap    int
+	protectedCap int
+	mainCap      int
+
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	nodeIndex map[interface{}]*tinyLFUNode
+	sketch    *cmSketch
+	bytes     int64 // estimated bytes of entries
+	mutex     sync.Mutex
+	lc        *lifecycle.Lifecycle
+	stats     cache.StatsCounter
+	evicts    evictQueue
+}
+
+type tinyLFUNode struct {
+	node
+	region int
+	el     *list.Element
+}
+
+func NewTinyLFUCache(cap int) *tinyLFUCache {
+	return NewTinyLFUCacheWithConfig(cap, DefaultTinyLFUCacheConfig)
+}
+
+func NewTinyLFUCacheWithConfig(cap int, cfg TinyLFUCacheConfig) *tinyLFUCache {
+	c := &tinyLFUCache{
+		Cap:                cap,
+		TinyLFUCacheConfig: cfg,
+		window:             list.New(),
+		probation:          list.New(),
+		protected:          list.New(),
+		nodeIndex:          make(map[interface{}]*tinyLFUNode),
+		sketch:             newCMSketch(cap),
+		lc:                 lifecycle.New(),
+		evicts:             evictQueue{fn: cfg.OnEvict},
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	c.resize(cap)
+	c.lc.Every(c.Clock, c.GCInterval, c.GC)
+	return c
+}
+
+func (c *tinyLFUCache) lock() {
+	c.mutex.Lock()
+}
+
+// unlock unlocks the cache, and then calls OnEvict with the entries left while it's locked.
+func (c *tinyLFUCache) unlock() {
+	queue := c.evicts.take()
+	c.mutex.Unlock()
+	c.evicts.call(queue)
+}
+
+// Close stops the background GC and waits for it until ctx is done.
+func (c *tinyLFUCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
+
+func (c *tinyLFUCache) resize(cap int) {
+	c.windowCap = int(float64(cap) * c.WindowRatio)
+	if c.windowCap < 1 {
+		c.windowCap = 1
+	}
+	c.mainCap = cap - c.windowCap
+	if c.mainCap < 0 {
+		c.mainCap = 0
+	}
+	c.protectedCap = int(float64(c.mainCap) * c.ProtectedRatio)
+}
+
+func (c *tinyLFUCache) GC() {
+	c.lock()
+	defer c.unlock()
+	defer c.stats.AddGCRuns(1)
+
+	removedNum := 0
+	now := c.Clock.Now()
+	for _, l := range []*list.List{c.window, c.probation, c.protected} {
+		for e := l.Front(); e != nil; {
+			if removedNum > c.GCOnceSize {
+				return
+			}
+			next := e.Next()
+			n := e.Value.(*tinyLFUNode)
+			if c.nodeIsExpired(n, now) {
+				removedNum++
+				c.reclaim(n.key)
+			}
+			e = next
+		}
+	}
+}
+
+func (c *tinyLFUCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+	v, err := c.get(key)
+	c.stats.Hit(err == nil)
+	return v, err
+}
+
+func (c *tinyLFUCache) Set(key interface{}, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+	return c.set(key, value, &o)
+}
+
+func (c *tinyLFUCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	ret := make(map[interface{}]interface{})
+	for _, key := range keys {
+		v, err := c.get(key)
+		if err != nil {
+			continue
+		}
+		ret[key] = v
+	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
+	return ret, nil
+}
+
+func (c *tinyLFUCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	c.lock()
+	defer c.unlock()
+
+	for k, v := range keyValues {
+		if err := c.set(k, v, &o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *tinyLFUCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	n, ok := c.nodeIndex[key]
+	if !ok {
+		return false, nil
+	}
+	if c.nodeIsExpired(n, c.Clock.Now()) {
+		c.reclaim(key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *tinyLFUCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if _, ok := c.nodeIndex[key]; ok {
+		c.stats.AddDeletes(1)
+	}
+	return c.del(key, cache.EvictDeleted)
+}
+
+func (c *tinyLFUCache) Clear(options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.stats.AddDeletes(len(c.nodeIndex))
+	if c.OnEvict != nil {
+		for _, n := range c.nodeIndex {
+			c.evicts.push(n.key, n.value, cache.EvictCleared)
+		}
+	}
+	c.bytes = 0
+	c.window = list.New()
+	c.probation = list.New()
+	c.protected = list.New()
+	c.nodeIndex = make(map[interface{}]*tinyLFUNode)
+	c.sketch = newCMSketch(c.Cap)
+	return nil
+}
+
+func (c *tinyLFUCache) Codec() cache.Codec {
+	return nil
+}
+
+// Stats reports the entries and their estimated bytes kept on writes, expired entries are unknown.
+func (c *tinyLFUCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	c.lock()
+	defer c.unlock()
+	stats.Entries = int64(len(c.nodeIndex))
+	stats.Expired = -1
+	stats.Bytes = c.bytes
+	return stats, nil
+}
+
+func (c *tinyLFUCache) list(region int) *list.List {
+	switch region {
+	case regionWindow:
+		return c.window
+	case regionProbation:
+		return c.probation
+	default:
+		return c.protected
+	}
+}
+
+// del removes key for reason.
+func (c *tinyLFUCache) del(key interface{}, reason cache.EvictReason) error {
+	if n, ok := c.nodeIndex[key]; ok {
+		c.evicts.push(key, n.value, reason)
+		c.list(n.region).Remove(n.el)
+		c.bytes -= n.size
+		delete(c.nodeIndex, key)
+	}
+	return nil
+}
+
+// reclaim removes the expired key.
+func (c *tinyLFUCache) reclaim(key interface{}) {
+	c.del(key, cache.EvictExpired)
+	c.stats.AddExpirations(1)
+}
+
+func (c *tinyLFUCache) nodeIsExpired(n *tinyLFUNode, deadline time.Time) bool {
+	ttl := c.TTL
+	if n.ttl > 0 {
+		ttl = n.ttl
+	}
+	if deadline.Sub(n.lastVisit) > ttl {
+		return true
+	}
+	return false
+}
+
+func (c *tinyLFUCache) get(key interface{}) (interface{}, error) {
+	c.sketch.increment(keyHash(key))
+
+	n, ok := c.nodeIndex[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+
+	now := c.Clock.Now()
+	if c.nodeIsExpired(n, now) {
+		c.reclaim(key)
+		return nil, cache.ErrNotFound
+	}
+	n.lastVisit = now
+	c.touch(n)
+
+	return n.value, nil
+}
+
+func (c *tinyLFUCache) set(key, value interface{}, o *cache.Options) error {
+	if o.Cost > 0 {
+		return cache.ErrUnsupported
+	}
+
+	c.sketch.increment(keyHash(key))
+
+	c.stats.AddSets(1)
+	now := c.Clock.Now()
+	if n, ok := c.nodeIndex[key]; ok {
+		reason := cache.EvictReplaced
+		if c.nodeIsExpired(n, now) {
+			reason = cache.EvictExpired
+		}
+		c.evicts.push(key, n.value, reason)
+		c.bytes += n.setValue(value)
+		n.ttl = o.TTL
+		n.lastVisit = now
+		c.touch(n)
+		return nil
+	}
+
+	n := &tinyLFUNode{
+		node:   node{key: key, lastVisit: now, ttl: o.TTL},
+		region: regionWindow,
+	}
+	c.bytes += n.setValue(value)
+	n.el = c.window.PushBack(n)
+	c.nodeIndex[key] = n
+
+	if c.window.Len() > c.windowCap {
+		c.admit(c.window.Front().Value.(*tinyLFUNode))
+	}
+	return nil
+}
+
+// touch moves n to the most recently used position of its segment, a node of probation is promoted to protected.
+func (c *tinyLFUCache) touch(n *tinyLFUNode) {
+	switch n.region {
+	case regionWindow:
+		c.window.MoveToBack(n.el)
+	case regionProtected:
+		c.protected.MoveToBack(n.el)
+	case regionProbation:
+		c.probation.Remove(n.el)
+		n.region = regionProtected
+		n.el = c.protected.PushBack(n)
+		if c.protected.Len() > c.protectedCap {
+			// demote the least recently used one of protected
+			d := c.protected.Front().Value.(*tinyLFUNode)
+			c.protected.Remove(d.el)
+			d.region = regionProbation
+			d.el = c.probation.PushBack(d)
+		}
+	}
+}
+
+// admit moves the candidate evicted from window to main region if there is room or no capacity limit,
+// otherwise the one less frequently used between candidate and the victim of probation is evicted.
+func (c *tinyLFUCache) admit(candidate *tinyLFUNode) {
+	c.window.Remove(candidate.el)
+	if c.Cap > 0 && c.probation.Len()+c.protected.Len() >= c.mainCap {
+		victim := c.probation.Front()
+		if victim == nil {
+			victim = c.protected.Front()
+		}
+		if victim == nil {
+			c.reject(candidate)
+			return
+		}
+		vn := victim.Value.(*tinyLFUNode)
+		if c.sketch.estimate(keyHash(candidate.key)) <= c.sketch.estimate(keyHash(vn.key)) {
+			c.reject(candidate)
+			return
+		}
+		c.del(vn.key, cache.EvictCapacity)
+		c.stats.AddEvictions(1)
+	}
+	candidate.region = regionProbation
+	candidate.el = c.probation.PushBack(candidate)
+}
+
+// reject evicts the candidate which is removed from window already.
+func (c *tinyLFUCache) reject(candidate *tinyLFUNode) {
+	c.evicts.push(candidate.key, candidate.value, cache.EvictCapacity)
+	c.bytes -= candidate.size
+	delete(c.nodeIndex, candidate.key)
+	c.stats.AddEvictions(1)
+}
+
+const sketchDepth = 4
+
+// cmSketch is a count-min sketch of 4 rows with counters saturated at 15.
+// All counters are halved when the number of increments reaches 10 times the capacity.
+type cmSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCMSketch(cap int) *cmSketch {
+	width := 16
+	for width < cap {
+		width <<= 1
+	}
+	s := &cmSketch{
+		mask:    uint64(width - 1),
+		resetAt: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *cmSketch) index(h uint64, row int) uint64 {
+	return mix64(h+uint64(row)*0x9e3779b97f4a7c15) & s.mask
+}
+
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(h uint64) uint8 {
+	min := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
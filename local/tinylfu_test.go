@@ -0,0 +1,212 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TinyLFUCacheGet(t *testing.T) {
+	n := 10
+	c := NewTinyLFUCache(n * 10)
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			c.Set(fmt.Sprintf("%d", i), i, cache.WithTTL(time.Second))
+		} else {
+			c.Set(fmt.Sprintf("%d", i), i)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+
+	time.Sleep(2 * time.Second) // wait for expires
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(fmt.Sprintf("%d", i))
+		if i == 0 {
+			assert.Equal(t, cache.ErrNotFound, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, i, v)
+		}
+	}
+}
+
+func Test_TinyLFUCacheCap(t *testing.T) {
+	cap := 100
+	c := NewTinyLFUCache(cap)
+	for i := 0; i < cap*10; i++ {
+		c.Set(i, i)
+		assert.True(t, len(c.nodeIndex) <= cap)
+	}
+	assert.Equal(t, c.window.Len()+c.probation.Len()+c.protected.Len(), len(c.nodeIndex))
+
+	assert.NoError(t, c.Clear())
+	assert.Equal(t, 0, len(c.nodeIndex))
+}
+
+func Test_TinyLFUCacheCost(t *testing.T) {
+	c := NewTinyLFUCache(10)
+	assert.Equal(t, cache.ErrUnsupported, c.Set(1, 1, cache.WithCost(10)))
+	assert.Equal(t, cache.ErrUnsupported, c.MSet(map[interface{}]interface{}{1: 1}, cache.WithCost(10)))
+	ok, _ := c.Exists(1)
+	assert.False(t, ok)
+}
+
+// zipfTrace generates a trace of n accesses to keys following zipf distribution.
+func zipfTrace(seed int64, keys uint64, n int) []interface{} {
+	z := rand.NewZipf(rand.New(rand.NewSource(seed)), 1.1, 1, keys)
+	trace := make([]interface{}, n)
+	for i := range trace {
+		trace[i] = z.Uint64()
+	}
+	return trace
+}
+
+// scanTrace interleaves trace with sequential scans over keys never seen before.
+func scanTrace(trace []interface{}, every, length int) []interface{} {
+	ret := make([]interface{}, 0, len(trace)*2)
+	next := uint64(1 << 32)
+	for i, key := range trace {
+		ret = append(ret, key)
+		if i%every == 0 {
+			for j := 0; j < length; j++ {
+				ret = append(ret, next)
+				next++
+			}
+		}
+	}
+	return ret
+}
+
+// hitRatio replays trace on c, a missing key is set after Get.
+func hitRatio(c cache.Cache, trace []interface{}) float64 {
+	hits := 0
+	for _, key := range trace {
+		if _, err := c.Get(key); err == nil {
+			hits++
+		} else {
+			c.Set(key, key)
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func Test_TinyLFUCacheHitRatio(t *testing.T) {
+	cap := 1000
+	trace := zipfTrace(1, 100000, 200000)
+
+	lru := hitRatio(NewLRUCache(cap), trace)
+	tinyLFU := hitRatio(NewTinyLFUCache(cap), trace)
+	t.Logf("zipf: lru %.4f, tinylfu %.4f", lru, tinyLFU)
+	assert.True(t, tinyLFU > lru)
+
+	trace = scanTrace(trace, 100, 200)
+	lru = hitRatio(NewLRUCache(cap), trace)
+	tinyLFU = hitRatio(NewTinyLFUCache(cap), trace)
+	t.Logf("zipf with scans: lru %.4f, tinylfu %.4f", lru, tinyLFU)
+	assert.True(t, tinyLFU > lru)
+}
+
+func Test_TinyLFUCacheClock(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewTinyLFUCacheWithConfig(10, TinyLFUCacheConfig{
+		TTL:            time.Minute,
+		GCInterval:     time.Minute,
+		GCOnceSize:     10,
+		WindowRatio:    0.2,
+		ProtectedRatio: 0.8,
+		Clock:          clock,
+	})
+	defer c.Close(context.Background())
+
+	c.Set("default", 1)
+	c.Set("short", 2, cache.WithTTL(time.Second))
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	v, err := c.Get("default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// the background GC is scheduled by the clock
+	clock.Advance(2 * time.Minute)
+	assert.Eventually(t, func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return len(c.nodeIndex) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_TinyLFUCacheStats(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	c := NewTinyLFUCacheWithConfig(2, TinyLFUCacheConfig{
+		TTL:            time.Hour,
+		WindowRatio:    0.5,
+		ProtectedRatio: 0.8,
+		Clock:          clock,
+	})
+
+	c.Set("a", 1)
+	c.Set("b", 2, cache.WithTTL(time.Second))
+	c.Get("b")
+	c.Get("b")
+	c.Set("c", 3) // b is admitted to main, a is left in window
+	c.Get("x")
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), s.Expired)
+	assert.Equal(t, uint64(2), s.Hits)
+	assert.Equal(t, uint64(1), s.Misses)
+	assert.Equal(t, uint64(3), s.Sets)
+	assert.Equal(t, s.Entries*9, s.Bytes)
+	assert.Equal(t, int64(3), s.Entries+int64(s.Evictions))
+
+	c.Get("b")
+	s, _ = c.Stats()
+	assert.Equal(t, uint64(1), s.Expirations)
+	assert.NoError(t, c.Clear())
+	s, _ = c.Stats()
+	assert.Equal(t, int64(0), s.Entries)
+	assert.Equal(t, int64(0), s.Bytes)
+}
+
+func Test_TinyLFUCacheOnEvict(t *testing.T) {
+	clock := cache.NewFakeClock(time.Now())
+	var c *tinyLFUCache
+	var evicted []string
+	c = NewTinyLFUCacheWithConfig(2, TinyLFUCacheConfig{
+		TTL:            time.Hour,
+		WindowRatio:    0.5,
+		ProtectedRatio: 0.8,
+		Clock:          clock,
+		OnEvict: func(key, value interface{}, reason cache.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%v=%v %v", key, value, reason))
+			c.Exists(key) // the cache isn't locked
+		},
+	})
+
+	c.Set("a", 1)
+	c.Set("a", 2)
+	c.Set("b", 1, cache.WithTTL(time.Second)) // a is admitted to main
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+	}
+	c.Set("c", 1) // b isn't admitted, as it's used less than a
+	clock.Advance(time.Minute)
+	c.Delete("c")
+	c.Clear()
+	assert.Equal(t, []string{"a=1 replaced", "b=1 capacity", "c=1 deleted", "a=2 cleared"}, evicted)
+}
@@ -6,8 +6,13 @@ import (
 )
 
 type Options struct {
-	TTL time.Duration
-	Ctx context.Context
+	TTL           time.Duration
+	StaleTTL      time.Duration
+	RefreshAhead  time.Duration
+	RecomputeTime time.Duration
+	Beta          float64
+	Cost          int64
+	Ctx           context.Context
 }
 
 func (o *Options) Apply(options ...Option) {
@@ -17,6 +22,26 @@ func (o *Options) Apply(options ...Option) {
 	}
 }
 
+// HardTTL returns the duration after which the entry is removed from cache, 0 if it never expires.
+func (o *Options) HardTTL() time.Duration {
+	if o.TTL <= 0 {
+		return 0
+	}
+	return o.TTL + o.StaleTTL
+}
+
+// SoftTTL returns the duration after which the entry is stale and should be refreshed,
+// 0 if the entry has no soft expiry.
+func (o *Options) SoftTTL() time.Duration {
+	if o.TTL <= 0 || (o.StaleTTL <= 0 && o.RefreshAhead <= 0) {
+		return 0
+	}
+	if o.RefreshAhead > 0 && o.RefreshAhead < o.TTL {
+		return o.TTL - o.RefreshAhead
+	}
+	return o.TTL
+}
+
 type Option interface {
 	apply(*Options)
 }
@@ -40,3 +65,44 @@ func WithContext(ctx context.Context) Option {
 		o.Ctx = ctx
 	})
 }
+
+// WithStaleTTL keeps the entry for ttl more after it expires,
+// during which it's returned as stale while a background refresh runs.
+func WithStaleTTL(ttl time.Duration) Option {
+	return optionFunc(func(o *Options) {
+		o.StaleTTL = ttl
+	})
+}
+
+// WithRefreshAhead makes the entry stale the given duration before it expires,
+// so it's refreshed in background before callers miss it. Ignored if not less than TTL.
+func WithRefreshAhead(d time.Duration) Option {
+	return optionFunc(func(o *Options) {
+		o.RefreshAhead = d
+	})
+}
+
+// WithRecomputeTime records how long it took to compute the value being stored,
+// which is used by the early expiration of cache.WithEarlyExpiration().
+func WithRecomputeTime(d time.Duration) Option {
+	return optionFunc(func(o *Options) {
+		o.RecomputeTime = d
+	})
+}
+
+// WithEarlyExpiration enables probabilistic early expiration on reading.
+// An entry stored with cache.WithRecomputeTime() is reported stale before it expires with
+// a probability growing as it approaches its expiry. Larger beta favors earlier recomputation,
+// 1.0 is a good default.
+func WithEarlyExpiration(beta float64) Option {
+	return optionFunc(func(o *Options) {
+		o.Beta = beta
+	})
+}
+
+// WithCost overrides the cost of the entry being stored, which is counted against the max cost of cache.
+func WithCost(cost int64) Option {
+	return optionFunc(func(o *Options) {
+		o.Cost = cost
+	})
+}
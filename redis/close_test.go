@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/codec/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func Test_Close(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	rdb.Ping()
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	sweeper := cache.DefaultSweeperConfig
+	sweeper.Interval = time.Millisecond
+	caches := []cache.Cache{
+		NewStringCache(rdb, json.NewCodec(), nil),
+		NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{GCInterval: time.Millisecond}),
+		NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Sweeper: &sweeper}),
+	}
+	for _, c := range caches {
+		assert.NoError(t, c.Set(1, 1, cache.WithTTL(time.Millisecond)))
+		time.Sleep(5 * time.Millisecond) // let background work run
+
+		closer := c.(cache.Closer)
+		assert.NoError(t, closer.Close(context.Background()))
+		assert.Equal(t, cache.ErrClosed, closer.Close(context.Background()))
+
+		_, err := c.Get(1)
+		assert.Equal(t, cache.ErrClosed, err)
+		assert.Equal(t, cache.ErrClosed, c.Set(1, 1))
+		_, err = c.Exists(1)
+		assert.Equal(t, cache.ErrClosed, err)
+		assert.Equal(t, cache.ErrClosed, c.Clear())
+	}
+	// the client is still usable
+	assert.NoError(t, rdb.Ping().Err())
+}
@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ryanking8215/go-cache"
+)
+
+// envelopeMagic prefixes values stored with metadata.
+// 0xff never begins a valid UTF-8 text, so it doesn't clash with text codecs such as json.
+var envelopeMagic = []byte{0xff, 'g', 'c', 1}
+
+const (
+	flagSoftExpire byte = 1 << iota
+	flagRecompute
+	_ // reserved
+	flagVersion
+)
+
+// envelopeLua is the prefix of scripts which read or give versions of values, it follows the layout
+// of envelope.encode, where the version comes first.
+// version returns the 8 bytes of the version of v, or an empty string if v has none.
+// versioned returns v with the version ver, which must have none.
+const envelopeLua = `
+local magic = '\255gc\1'
+local function version(v)
+	if #v >= 13 and v:sub(1, 4) == magic and math.floor(v:byte(5) / 8) % 2 == 1 then
+		return v:sub(6, 13)
+	end
+	return ''
+end
+local function versioned(v, ver)
+	if #v < 5 or v:sub(1, 4) ~= magic then
+		return magic .. string.char(8) .. ver .. v
+	end
+	return magic .. string.char(v:byte(5) + 8) .. ver .. v:sub(6)
+end
+`
+
+// envelope carries the metadata stored alongside an encoded value.
+// Values without metadata are stored as is, so the layout stays compatible with plain values.
+type envelope struct {
+	version      uint64 // version for CompareAndSwap, 0 if the value has none
+	softExpireAt int64  // unix nano, 0 if the value has no soft expiry
+	expireAt     int64  // unix nano, only stored with delta
+	delta        int64  // nanoseconds taken to recompute the value, 0 if unknown
+	value        []byte
+}
+
+// newEnvelope encodes value by codec with the metadata required by o.
+func newEnvelope(codec cache.Codec, value interface{}, o *cache.Options, now time.Time) (envelope, error) {
+	b, err := codec.Encode(value)
+	if err != nil {
+		return envelope{}, err
+	}
+	e := envelope{value: b}
+	if softTTL := o.SoftTTL(); softTTL > 0 {
+		e.softExpireAt = now.Add(softTTL).UnixNano()
+	}
+	if ttl := o.HardTTL(); ttl > 0 && o.RecomputeTime > 0 {
+		e.expireAt = now.Add(ttl).UnixNano()
+		e.delta = int64(o.RecomputeTime)
+	}
+	return e, nil
+}
+
+// encodeValue is newEnvelope, and encodes the envelope.
+func encodeValue(codec cache.Codec, value interface{}, o *cache.Options, now time.Time) ([]byte, error) {
+	e, err := newEnvelope(codec, value, o, now)
+	if err != nil {
+		return nil, err
+	}
+	return e.encode(), nil
+}
+
+// encodeSwap is encodeValue for CompareAndSwap, the value gets the version next to the one swapped.
+func encodeSwap(codec cache.Codec, value interface{}, o *cache.Options, now time.Time, version uint64) ([]byte, error) {
+	e, err := newEnvelope(codec, value, o, now)
+	if err != nil {
+		return nil, err
+	}
+	e.version = version + 1
+	return e.encode(), nil
+}
+
+// newVersion returns the version given to a value without one by GetWithVersion.
+// Values set by other writes have no version, so it's random for a value set again not to get a version
+// seen before, and odd to never be 0.
+func newVersion() []byte {
+	return versionBytes(rand.Uint64() | 1)
+}
+
+// versionBytes returns version as stored in the envelope, to be compared by lua scripts.
+func versionBytes(version uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, version)
+}
+
+// isStale reports whether the value should be refreshed.
+// Besides the soft expiry, a value with recompute time is stale with a probability growing
+// as it approaches its expiry if beta > 0, see "Optimal Probabilistic Cache Stampede Prevention".
+func (e *envelope) isStale(now time.Time, beta float64) bool {
+	if e.softExpireAt > 0 && now.UnixNano() > e.softExpireAt {
+		return true
+	}
+	if beta <= 0 || e.delta <= 0 || e.expireAt <= 0 {
+		return false
+	}
+	early := float64(e.delta) * beta * -math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+early >= float64(e.expireAt)
+}
+
+func (e *envelope) encode() []byte {
+	var flags byte
+	if e.version != 0 {
+		flags |= flagVersion
+	}
+	if e.softExpireAt > 0 {
+		flags |= flagSoftExpire
+	}
+	if e.delta > 0 {
+		flags |= flagRecompute
+	}
+	if flags == 0 {
+		return e.value
+	}
+
+	b := make([]byte, 0, len(envelopeMagic)+1+8*4+len(e.value))
+	b = append(b, envelopeMagic...)
+	b = append(b, flags)
+	if flags&flagVersion != 0 {
+		b = binary.BigEndian.AppendUint64(b, e.version)
+	}
+	if flags&flagSoftExpire != 0 {
+		b = binary.BigEndian.AppendUint64(b, uint64(e.softExpireAt))
+	}
+	if flags&flagRecompute != 0 {
+		b = binary.BigEndian.AppendUint64(b, uint64(e.expireAt))
+		b = binary.BigEndian.AppendUint64(b, uint64(e.delta))
+	}
+	return append(b, e.value...)
+}
+
+func decodeEnvelope(b []byte) envelope {
+	if !bytes.HasPrefix(b, envelopeMagic) || len(b) < len(envelopeMagic)+1 {
+		return envelope{value: b}
+	}
+	flags := b[len(envelopeMagic)]
+	rest := b[len(envelopeMagic)+1:]
+
+	var e envelope
+	if flags&flagVersion != 0 {
+		if len(rest) < 8 {
+			return envelope{value: b}
+		}
+		e.version = binary.BigEndian.Uint64(rest)
+		rest = rest[8:]
+	}
+	if flags&flagSoftExpire != 0 {
+		if len(rest) < 8 {
+			return envelope{value: b}
+		}
+		e.softExpireAt = int64(binary.BigEndian.Uint64(rest))
+		rest = rest[8:]
+	}
+	if flags&flagRecompute != 0 {
+		if len(rest) < 16 {
+			return envelope{value: b}
+		}
+		e.expireAt = int64(binary.BigEndian.Uint64(rest))
+		e.delta = int64(binary.BigEndian.Uint64(rest[8:]))
+		rest = rest[16:]
+	}
+	e.value = rest
+	return e
+}
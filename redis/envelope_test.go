@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_envelope(t *testing.T) {
+	plain := envelope{value: []byte(`"value"`)}
+	assert.Equal(t, plain.value, plain.encode())
+	assert.Equal(t, plain, decodeEnvelope(plain.encode()))
+
+	e := envelope{
+		version:      4,
+		softExpireAt: 1,
+		expireAt:     2,
+		delta:        3,
+		value:        []byte(`"value"`),
+	}
+	assert.Equal(t, e, decodeEnvelope(e.encode()))
+}
+
+func Test_envelopeEarlyExpiration(t *testing.T) {
+	now := time.Now()
+	e := envelope{
+		expireAt: now.Add(time.Minute).UnixNano(),
+		delta:    int64(time.Hour),
+	}
+	stale := 0
+	for i := 0; i < 100; i++ {
+		if e.isStale(now, 1) {
+			stale++
+		}
+		assert.False(t, e.isStale(now, 0)) // disabled
+	}
+	assert.True(t, stale > 0)
+
+	e.delta = int64(time.Millisecond) // cheap to recompute, far from expiry
+	for i := 0; i < 100; i++ {
+		assert.False(t, e.isStale(now, 1))
+	}
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/ryanking8215/go-cache"
+)
+
+// generation is the generation of a namespace cached locally.
+type generation struct {
+	mu       sync.Mutex
+	val      int64
+	expireAt time.Time
+}
+
+// get returns the generation cached, and whether it's still fresh at now.
+func (g *generation) get(now time.Time) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.val, now.Before(g.expireAt)
+}
+
+// fetched caches val fetched until expireAt, unless a fresher one was cached during the fetch,
+// and returns the one cached.
+func (g *generation) fetched(val int64, expireAt time.Time) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if expireAt.After(g.expireAt) {
+		g.val, g.expireAt = val, expireAt
+	}
+	return g.val
+}
+
+func (c *stringCache) generationKey() string {
+	return c.Prefix + "generation"
+}
+
+// keyPrefix returns the prefix of keys, Prefix followed by the current generation if Generation is enabled.
+// The generation is fetched without holding the lock, so a slow fetch doesn't block the others.
+func (c *stringCache) keyPrefix(o *cache.Options) (string, error) {
+	if !c.Generation {
+		return c.Prefix, nil
+	}
+
+	now := c.Clock.Now()
+	val, fresh := c.gen.get(now)
+	if !fresh {
+		var err error
+		val, err = c.rdb.DoContext(o.Ctx, "GET", c.generationKey()).Int64()
+		if err != nil && err != redis.Nil {
+			if notRedisError(err) {
+				return "", err
+			}
+			return "", cache.NewCacheError(err)
+		}
+		val = c.gen.fetched(val, now.Add(c.GenerationCacheTTL))
+	}
+	return c.Prefix + strconv.FormatInt(val, 10) + ":", nil
+}
+
+// incrGeneration moves the namespace to the next generation, which is cached locally at once.
+func (c *stringCache) incrGeneration(o *cache.Options) error {
+	val, err := c.rdb.DoContext(o.Ctx, "INCR", c.generationKey()).Int64()
+	if err != nil {
+		if notRedisError(err) {
+			return err
+		}
+		return cache.NewCacheError(err)
+	}
+
+	now := c.Clock.Now()
+	c.gen.mu.Lock()
+	defer c.gen.mu.Unlock()
+	if val > c.gen.val || !now.Before(c.gen.expireAt) {
+		c.gen.val = val
+		c.gen.expireAt = now.Add(c.GenerationCacheTTL)
+	}
+	return nil
+}
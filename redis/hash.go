@@ -1,16 +1,32 @@
 package redis
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v7"
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
 )
 
 type HashCacheConfig struct {
 	GCInterval time.Duration
 	GCOnceSize int
+	// Sweeper enables the adaptive sweeper instead of GCInterval, if not nil.
+	Sweeper *cache.SweeperConfig
+	// Clock tells the time for expiration and schedules GC, cache.RealClock is used if nil.
+	// Native field ttl goes by the time of redis server instead.
+	Clock cache.Clock
+	// HashTagTimeout names the timeout zset "{keyName}.timeout" instead of "keyName.timeout", which is
+	// in the slot of keyName, as scripts require in cluster mode. A keyName which has a hash tag doesn't need it.
+	HashTagTimeout bool
+	// NativeTTL makes fields expire by field ttl (HPEXPIRE of redis 7.4+) instead of the timeout zset,
+	// if the server supports it. Expire times in the zset, written by clients without it, are still
+	// checked and removed by GC, until MigrateToNativeTTL moves them.
+	NativeTTL bool
 }
 
 var DefaultHashCacheConfig = HashCacheConfig{
@@ -19,6 +35,17 @@ var DefaultHashCacheConfig = HashCacheConfig{
 }
 
 var _ cache.Cache = (*hashCache)(nil)
+var _ cache.StaleGetter = (*hashCache)(nil)
+var _ cache.Sweepable = (*hashCache)(nil)
+var _ cache.Closer = (*hashCache)(nil)
+var _ cache.Expirer = (*hashCache)(nil)
+var _ cache.Counter = (*hashCache)(nil)
+var _ cache.ConditionalSetter = (*hashCache)(nil)
+var _ cache.Scanner = (*hashCache)(nil)
+var _ cache.StatsReporter = (*hashCache)(nil)
+
+// gcBatchSize is the most fields removed by one GC script, not to block redis for long.
+const gcBatchSize = 1000
 
 type hashCache struct {
 	codec      cache.Codec
@@ -26,8 +53,16 @@ type hashCache struct {
 	keyName    string
 	timeoutKey string
 	HashCacheConfig
+	sweeper *cache.Sweeper
+	lc      *lifecycle.Lifecycle
+	stats   cache.StatsCounter
+
+	ttlMode int32 // ttlModeXxx, accessed atomically
+	modeMu  sync.Mutex
 }
 
+// NewHashCache stores values in the hash keyName and expire times in the zset "keyName.timeout",
+// or "{keyName}.timeout" with HashTagTimeout.
 func NewHashCache(rdb redis.UniversalClient, codec cache.Codec, keyName string, cfg *HashCacheConfig) *hashCache {
 	c := hashCache{
 		rdb:             rdb,
@@ -35,234 +70,537 @@ func NewHashCache(rdb redis.UniversalClient, codec cache.Codec, keyName string,
 		keyName:         keyName,
 		timeoutKey:      keyName + ".timeout",
 		HashCacheConfig: DefaultHashCacheConfig,
+		lc:              lifecycle.New(),
 	}
 	if cfg != nil {
 		c.HashCacheConfig = *cfg
 	}
-	if c.GCInterval > 0 {
-		go c.runGC()
+	if c.HashTagTimeout && !hasHashTag(keyName) {
+		c.timeoutKey = "{" + keyName + "}.timeout"
+	}
+	if c.Clock == nil {
+		c.Clock = cache.RealClock
+	}
+	if c.Sweeper != nil {
+		c.sweeper = cache.NewSweeper(&c, *c.Sweeper)
+		c.lc.Every(c.Clock, c.sweeper.Interval, c.sweeper.Sweep)
+	} else {
+		c.lc.Every(c.Clock, c.GCInterval, c.GC)
 	}
 
 	return &c
 }
 
-func (c *hashCache) runGC() {
-	tick := time.NewTicker(c.GCInterval)
-	defer tick.Stop()
+// Close stops the background GC and waits for it until ctx is done, the redis client is not closed.
+func (c *hashCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
+}
 
-	for range tick.C {
-		c.GC()
+// GC removes the fields expired, in batches of gcBatchSize.
+func (c *hashCache) GC() {
+	var o cache.Options
+	o.Apply()
+	c.stats.AddGCRuns(1)
+	for {
+		n, err := hashGCScript.run(o.Ctx, c.rdb, c.keys(), c.Clock.Now().UnixNano(), gcBatchSize).Int()
+		c.stats.AddExpirations(n)
+		if err != nil || n < gcBatchSize {
+			return
+		}
 	}
 }
 
-func (c *hashCache) GC() {
-	cmd := c.rdb.ZRangeByScore(c.timeoutKey, &redis.ZRangeBy{
-		"-inf", timeUnixNanoToString(time.Now()), 0, 0,
-	})
-	fields, err := cmd.Result()
+// SweepSample removes the expired ones of at most n fields which expire the earliest.
+func (c *hashCache) SweepSample(n int) (int, int, error) {
+	var o cache.Options
+	o.Apply()
+	ret, err := hashSweepScript.run(o.Ctx, c.rdb, c.keys(), c.Clock.Now().UnixNano(), n).Result()
 	if err != nil {
-		return
+		return 0, 0, cache.NewCacheError(err)
 	}
-	_ = c.rdb.HDel(c.keyName, fields...)
+	vals, ok := ret.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 0, 0, cache.NewCacheError(errors.New("count not match"))
+	}
+	sampled, _ := vals[0].(int64)
+	removed, _ := vals[1].(int64)
+	c.stats.AddGCRuns(1)
+	c.stats.AddExpirations(int(removed))
+	return int(sampled), int(removed), nil
+}
+
+// SweeperStats returns the counters of the sweeper, zero if it's not enabled.
+func (c *hashCache) SweeperStats() cache.SweeperStats {
+	if c.sweeper == nil {
+		return cache.SweeperStats{}
+	}
+	return c.sweeper.Stats()
 }
 
 func (c *hashCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	v, _, err := c.GetStale(key, options...)
+	return v, err
+}
+
+// GetStale is like Get, but also reports whether the value is past its soft expiry,
+// or is chosen to be recomputed early by cache.WithEarlyExpiration().
+func (c *hashCache) GetStale(key interface{}, options ...cache.Option) (interface{}, bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, false, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	field := toString(key, c.codec)
-	pipeline := c.rdb.Pipeline()
+	now := c.Clock.Now()
+	ret, err := c.get(&o, toString(key, c.codec), now)
+	c.stats.Hit(err == nil)
+	if err != nil {
+		return nil, false, err
+	}
+	e := decodeEnvelope([]byte(ret))
+	v, err := c.codec.Decode(e.value)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, e.isStale(now, o.Beta), nil
+}
 
-	cmd := pipeline.HGet(c.keyName, field)
-	scoreCmd := pipeline.ZScore(c.timeoutKey, field)
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
+// get returns the raw value of field, cache.ErrNotFound if it's missing or expired.
+// The timeout zset is checked with native field ttl as well.
+func (c *hashCache) get(o *cache.Options, field string, now time.Time) (string, error) {
+	ret, err := hashGetScript.run(o.Ctx, c.rdb, c.keys(), field, now.UnixNano()).String()
+	if err != nil {
+		if err == redis.Nil {
+			return "", cache.ErrNotFound
+		}
 		if notRedisError(err) {
-			return nil, err
+			return "", err
 		}
+		return "", cache.NewCacheError(err)
 	}
+	return ret, nil
+}
 
-	ret, err := cmd.Result()
+func (c *hashCache) Set(key, value interface{}, options ...cache.Option) error {
+	return c.MSet(map[interface{}]interface{}{key: value}, options...)
+}
+
+func (c *hashCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, c.Clock.Now().UnixNano())
+	for _, key := range keys {
+		args = append(args, toString(key, c.codec))
+	}
+	res, err := hashMGetScript.run(o.Ctx, c.rdb, c.keys(), args...).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return nil, cache.ErrNotFound
+		if notRedisError(err) {
+			return nil, err
 		}
 		return nil, cache.NewCacheError(err)
 	}
-	expire, err := scoreCmd.Result()
-	if err != nil {
-		if err == redis.Nil {
-			expire = 0
-		} else {
-			return nil, cache.NewCacheError(err)
-		}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != len(keys) {
+		return nil, cache.NewCacheError(errors.New("count not match"))
 	}
-	if expire > 0 && int64(expire) < time.Now().UnixNano() {
-		_ = c.del(&o, field)
-		return nil, cache.ErrNotFound
+
+	ret := make(map[interface{}]interface{})
+	for i, key := range keys {
+		str, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		e := decodeEnvelope([]byte(str))
+		v, err := c.codec.Decode(e.value)
+		if err != nil {
+			continue
+		}
+		ret[key] = v
 	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
 
-	return c.codec.Decode([]byte(ret))
+	return ret, nil
 }
 
-func (c *hashCache) Set(key, value interface{}, options ...cache.Option) error {
+// MSet sets the values and their expiry in one script, the former expiry is removed if there is no ttl.
+func (c *hashCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	field := toString(key, c.codec)
-	b, err := c.codec.Encode(value)
+	native, err := c.native(o.Ctx)
 	if err != nil {
 		return err
 	}
+	now := c.Clock.Now()
+	ttl := o.HardTTL()
+	args := make([]interface{}, 0, len(keyValues)*2+1)
+	switch {
+	case native:
+		args = append(args, milliseconds(ttl))
+	case ttl > 0:
+		args = append(args, now.Add(ttl).UnixNano())
+	default:
+		args = append(args, 0)
+	}
+	for k, v := range keyValues {
+		b, err := encodeValue(c.codec, v, &o, now)
+		if err != nil {
+			return err
+		}
+		args = append(args, toString(k, c.codec), b)
+	}
 
-	pipeline := c.rdb.Pipeline()
-	pipeline.HSet(c.keyName, field, b)
-	if o.TTL > 0 {
-		t := time.Now().Add(o.TTL)
-		pipeline.ZAdd(c.timeoutKey, &redis.Z{Score: float64(t.UnixNano()), Member: field})
+	s := hashSetScript
+	if native {
+		s = nativeSetScript
 	}
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
+	if err := s.run(o.Ctx, c.rdb, c.keys(), args...).Err(); err != nil {
 		if notRedisError(err) {
 			return err
 		}
 		return cache.NewCacheError(err)
 	}
+	c.stats.AddSets(len(keyValues))
 
 	return nil
 }
 
-func (c *hashCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+func (c *hashCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	fields := make([]string, 0, len(keys))
-	for _, key := range keys {
-		fields = append(fields, toString(key, c.codec))
+	_, err := c.alive(&o, toString(key, c.codec))
+	if err == cache.ErrNotFound {
+		return false, nil
 	}
-
-	pipeline := c.rdb.Pipeline()
-	cmd := pipeline.HMGet(c.keyName, fields...)
-	scoreCmds := make([]*redis.FloatCmd, 0, len(fields))
-	for _, field := range fields {
-		scoreCmds = append(scoreCmds, pipeline.ZScore(c.timeoutKey, field))
+	if err != nil {
+		return false, err
 	}
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
-		if notRedisError(err) {
-			return nil, err
-		}
+	return true, nil
+}
+
+func (c *hashCache) TTL(key interface{}, options ...cache.Option) (time.Duration, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
 	}
 
-	vals, err := cmd.Result()
+	var o cache.Options
+	o.Apply(options...)
+
+	expire, err := c.alive(&o, toString(key, c.codec))
 	if err != nil {
-		return nil, cache.NewCacheError(err)
+		return 0, err
 	}
-	if len(vals) != len(fields) {
-		return nil, cache.NewCacheError(errors.New("count not match"))
+	if expire == 0 {
+		return 0, nil
 	}
+	return time.Duration(expire - c.Clock.Now().UnixNano()), nil
+}
 
-	ret := make(map[interface{}]interface{})
-	for i, key := range keys {
-		if vals[i] == nil {
-			continue
-		}
-		timeout, err := scoreCmds[i].Result()
-		if err != nil && err != redis.Nil {
-			return nil, cache.NewCacheError(err)
-		}
-		if timeout > 0 && int64(timeout) < time.Now().UnixNano() {
-			continue
-		}
+func (c *hashCache) Expire(key interface{}, ttl time.Duration, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
 
-		b, ok := vals[i].([]byte)
-		if !ok {
-			continue
-		}
-		v, err := c.codec.Decode(b)
-		if err != nil {
-			continue
-		}
+	var o cache.Options
+	o.Apply(options...)
 
-		ret[key] = v
+	now := c.Clock.Now()
+	at := int64(-1)
+	if ttl > 0 {
+		at = now.Add(ttl).UnixNano()
+	}
+	return c.expire(&o, toString(key, c.codec), now, at)
+}
+
+func (c *hashCache) Persist(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
 	}
 
-	return ret, nil
+	var o cache.Options
+	o.Apply(options...)
+	return c.expire(&o, toString(key, c.codec), c.Clock.Now(), 0)
 }
 
-func (c *hashCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+// Touch renews the expiry of key with cache.WithTTL(), which is required as redis doesn't keep
+// the ttl a field was set with, cache.ErrUnsupported is returned without it.
+func (c *hashCache) Touch(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	fieldVals := make([]interface{}, 0, len(keyValues)*2)
-	zmembers := make([]*redis.Z, 0, len(keyValues))
-	var expire time.Time
-	if o.TTL > 0 {
-		expire = time.Now().Add(o.TTL)
+	ttl := o.HardTTL()
+	if ttl <= 0 {
+		return cache.ErrUnsupported
 	}
+	now := c.Clock.Now()
+	return c.expire(&o, toString(key, c.codec), now, now.Add(ttl).UnixNano())
+}
 
-	pipeline := c.rdb.Pipeline()
-	for k, v := range keyValues {
-		b, err := c.codec.Encode(v)
-		if err != nil {
-			return err
+// alive returns the expire time in unix nano of field, 0 if it never expires.
+// cache.ErrNotFound is returned if field is missing or expired, an expired one is deleted.
+func (c *hashCache) alive(o *cache.Options, field string) (int64, error) {
+	native, err := c.native(o.Ctx)
+	if err != nil {
+		return 0, err
+	}
+	if native {
+		return c.nativeAlive(o, field)
+	}
+	ret, err := hashAliveScript.run(o.Ctx, c.rdb, c.keys(), field, c.Clock.Now().UnixNano()).Float64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, cache.ErrNotFound
 		}
-		field := toString(k, c.codec)
-		fieldVals = append(fieldVals, field)
-		fieldVals = append(fieldVals, b)
-
-		if o.TTL > 0 {
-			zmembers = append(zmembers, &redis.Z{float64(expire.UnixNano()), field})
+		if notRedisError(err) {
+			return 0, err
 		}
+		return 0, cache.NewCacheError(err)
 	}
+	return int64(ret), nil
+}
 
-	pipeline.HMSet(c.keyName, fieldVals)
-	if o.TTL > 0 {
-		pipeline.ZAdd(c.timeoutKey, zmembers...)
+// expire sets the expire time of field to at in unix nano, 0 to persist it or -1 to delete it.
+func (c *hashCache) expire(o *cache.Options, field string, now time.Time, at int64) error {
+	native, err := c.native(o.Ctx)
+	if err != nil {
+		return err
+	}
+	var ret int64
+	if native {
+		ret, err = nativeExpireScript.run(o.Ctx, c.rdb, c.keys(), field, nativeTTL(now, at), now.UnixNano()).Int64()
+	} else {
+		ret, err = hashExpireScript.run(o.Ctx, c.rdb, c.keys(), field, now.UnixNano(), at).Int64()
 	}
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
+	if err != nil {
 		if notRedisError(err) {
 			return err
 		}
 		return cache.NewCacheError(err)
 	}
+	return casResult(ret)
+}
+
+func (c *hashCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *hashCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+// IncrBy increments the value of key by HINCRBY, the value is stored as a plain integer without codec.
+func (c *hashCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	cmd, err := c.incr(&o, toString(key, c.codec), "HINCRBY", delta)
+	if err != nil {
+		return 0, err
+	}
+	v, err := cmd.Int64()
+	if err != nil {
+		return 0, counterError(err)
+	}
+	c.stats.AddSets(1)
+	return v, nil
+}
+
+// IncrByFloat increments the value of key by HINCRBYFLOAT, the value is stored as a plain number without codec.
+func (c *hashCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	cmd, err := c.incr(&o, toString(key, c.codec), "HINCRBYFLOAT", delta)
+	if err != nil {
+		return 0, err
+	}
+	v, err := cmd.Float64()
+	if err != nil {
+		return 0, counterError(err)
+	}
+	c.stats.AddSets(1)
+	return v, nil
+}
 
+func (c *hashCache) incr(o *cache.Options, field string, cmd string, delta interface{}) (*redis.Cmd, error) {
+	native, err := c.native(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.Clock.Now()
+	if native {
+		return nativeIncrScript.run(o.Ctx, c.rdb, c.keys(), cmd, field, delta, milliseconds(o.HardTTL()), now.UnixNano()), nil
+	}
+	var expire int64
+	if ttl := o.HardTTL(); ttl > 0 {
+		expire = now.Add(ttl).UnixNano()
+	}
+	return hashIncrScript.run(o.Ctx, c.rdb, c.keys(), cmd, field, delta, now.UnixNano(), expire), nil
+}
+
+func (c *hashCache) Add(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	ret, err := c.setIf(&o, key, value, "NX", 0)
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return cache.ErrExisted
+	}
 	return nil
 }
 
-func (c *hashCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+func (c *hashCache) Replace(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	field := toString(key, c.codec)
+	ret, err := c.setIf(&o, key, value, "XX", 0)
+	if err != nil {
+		return err
+	}
+	return casResult(ret)
+}
 
-	pipeline := c.rdb.Pipeline()
-	cmd := pipeline.HExists(c.keyName, field)
-	scoreCmd := pipeline.ZScore(c.timeoutKey, field)
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
-		if notRedisError(err) {
-			return false, err
-		}
+// GetWithVersion is like Get, a value without a version gets one in its envelope, which is renewed by
+// CompareAndSwap and dropped by other writes.
+func (c *hashCache) GetWithVersion(key interface{}, options ...cache.Option) (interface{}, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
 	}
 
-	ret, err := cmd.Result()
+	var o cache.Options
+	o.Apply(options...)
+
+	native, err := c.native(o.Ctx)
 	if err != nil {
-		return false, cache.NewCacheError(err)
+		return nil, 0, err
 	}
-	if !ret {
-		return false, nil
+	field := toString(key, c.codec)
+	var ret string
+	if native {
+		ret, err = nativeGetVersionScript.run(o.Ctx, c.rdb, c.keys(), field, newVersion(), c.Clock.Now().UnixNano()).String()
+	} else {
+		ret, err = hashGetVersionScript.run(o.Ctx, c.rdb, c.keys(), field, c.Clock.Now().UnixNano(), newVersion()).String()
 	}
+	if err != nil {
+		if err == redis.Nil {
+			c.stats.Hit(false)
+			return nil, 0, cache.ErrNotFound
+		}
+		if notRedisError(err) {
+			return nil, 0, err
+		}
+		return nil, 0, cache.NewCacheError(err)
+	}
+	c.stats.Hit(true)
+	e := decodeEnvelope([]byte(ret))
+	v, err := c.codec.Decode(e.value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, e.version, nil
+}
 
-	expire, err := scoreCmd.Result()
-	if err != nil && err != redis.Nil {
-		return false, cache.NewCacheError(err)
+func (c *hashCache) CompareAndSwap(key, value interface{}, version uint64, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
 	}
-	if expire > 0 && int64(expire) < time.Now().UnixNano() {
-		_ = c.del(&o, field)
-		return false, nil
+
+	var o cache.Options
+	o.Apply(options...)
+
+	ret, err := c.setIf(&o, key, value, "CAS", version)
+	if err != nil {
+		return err
 	}
+	return casResult(ret)
+}
 
-	return true, nil
+// setIf runs hashSetIfScript with the condition cond, and returns the result of it.
+func (c *hashCache) setIf(o *cache.Options, key, value interface{}, cond string, version uint64) (int64, error) {
+	now := c.Clock.Now()
+	var b []byte
+	var err error
+	if cond == "CAS" {
+		b, err = encodeSwap(c.codec, value, o, now, version)
+	} else {
+		b, err = encodeValue(c.codec, value, o, now)
+	}
+	if err != nil {
+		return 0, err
+	}
+	native, err := c.native(o.Ctx)
+	if err != nil {
+		return 0, err
+	}
+	var ret int64
+	if native {
+		ret, err = nativeSetIfScript.run(o.Ctx, c.rdb, c.keys(),
+			toString(key, c.codec), b, milliseconds(o.HardTTL()), cond, versionBytes(version), now.UnixNano()).Int64()
+	} else {
+		var expire int64
+		if ttl := o.HardTTL(); ttl > 0 {
+			expire = now.Add(ttl).UnixNano()
+		}
+		ret, err = hashSetIfScript.run(o.Ctx, c.rdb, c.keys(),
+			toString(key, c.codec), b, now.UnixNano(), expire, cond, versionBytes(version)).Int64()
+	}
+	if err != nil {
+		if notRedisError(err) {
+			return 0, err
+		}
+		return 0, cache.NewCacheError(err)
+	}
+	if ret == 1 {
+		c.stats.AddSets(1)
+	}
+	return ret, nil
 }
 
 func (c *hashCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
@@ -271,27 +609,100 @@ func (c *hashCache) Delete(key interface{}, options ...cache.Option) error {
 }
 
 func (c *hashCache) del(o *cache.Options, fields ...string) error {
-	pipeline := c.rdb.Pipeline()
-	pipeline.HDel(c.keyName, fields...)
-	zmembers := make([]interface{}, 0, len(fields))
+	args := make([]interface{}, 0, len(fields))
 	for _, field := range fields {
-		zmembers = append(zmembers, field)
+		args = append(args, field)
 	}
-	pipeline.ZRem(c.timeoutKey, zmembers...)
-	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
+	n, err := hashDelScript.run(o.Ctx, c.rdb, c.keys(), args...).Int()
+	if err != nil {
 		if notRedisError(err) {
 			return err
 		}
+		return cache.NewCacheError(err)
 	}
+	c.stats.AddDeletes(n)
 	return nil
 }
 
+// Clear unlinks the hash and the timeout zset, so it's O(1) and redis frees them in the background.
 func (c *hashCache) Clear(options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+	pipe := c.rdb.TxPipeline()
+	n := pipe.HLen(c.keyName)
+	pipe.Unlink(c.keyName, c.timeoutKey)
+	if _, err := pipe.ExecContext(o.Ctx); err != nil {
+		return err
+	}
+	c.stats.AddDeletes(int(n.Val()))
+	return nil
+}
+
+// Stats tells the entries by HLEN, the expired ones by ZCOUNT of the timeout zset, which is unknown with
+// native field ttl, and the bytes by MEMORY USAGE, which is unknown if the server doesn't support it.
+// Counters are counted by this client only.
+func (c *hashCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
-	return c.rdb.DoContext(o.Ctx, "DEL", c.keyName, c.timeoutKey).Err()
+
+	native, err := c.native(o.Ctx)
+	if err != nil {
+		return cache.Stats{}, err
+	}
+	stats := c.stats.Stats()
+	pipe := c.rdb.Pipeline()
+	entries := pipe.HLen(c.keyName)
+	expired := pipe.ZCount(c.timeoutKey, "-inf", "("+strconv.FormatInt(c.Clock.Now().UnixNano(), 10))
+	bytes := pipe.Do("MEMORY", "USAGE", c.keyName)
+	zbytes := pipe.Do("MEMORY", "USAGE", c.timeoutKey)
+	pipe.ExecContext(o.Ctx)
+	if err := entries.Err(); err != nil {
+		if notRedisError(err) {
+			return cache.Stats{}, err
+		}
+		return cache.Stats{}, cache.NewCacheError(err)
+	}
+	stats.Entries = entries.Val()
+	stats.Expired = -1
+	if !native && expired.Err() == nil {
+		stats.Expired = expired.Val()
+	}
+	stats.Bytes = -1
+	if b, err := bytes.Int64(); err == nil || err == redis.Nil {
+		zb, _ := zbytes.Int64()
+		stats.Bytes = b + zb
+	}
+	return stats, nil
+}
+
+// Scan runs HSCAN over the fields, the expired ones are filtered out.
+func (c *hashCache) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if match == "" {
+		match = "*"
+	}
+	if count <= 0 {
+		count = 10 // the default COUNT of HSCAN
+	}
+	return scanResult(hashScanScript.run(ctx, c.rdb, c.keys(), cursor, match, count, c.Clock.Now().UnixNano()).Result())
 }
 
 func (c *hashCache) Codec() cache.Codec {
 	return c.codec
 }
+
+// keys returns the keys of scripts, the hash of values and the timeout zset.
+func (c *hashCache) keys() []string {
+	return []string{c.keyName, c.timeoutKey}
+}
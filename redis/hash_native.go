@@ -0,0 +1,282 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/ryanking8215/go-cache"
+)
+
+// ttl modes of hash cache
+const (
+	ttlModeUnknown int32 = iota
+	ttlModeZSet          // expire times are kept in the timeout zset, checked by scripts and removed by GC
+	ttlModeNative        // fields expire by HPEXPIRE of redis 7.4+, the timeout zset is still checked
+)
+
+// Scripts of native field ttl, KEYS[1] is the hash of values and KEYS[2] is the timeout zset.
+// Ttl is in milliseconds, 0 for none. Fields in the zset, written by clients without native field ttl,
+// expire by it as in the scripts of hash cache, and are removed from it once written.
+
+// nativeSetScript sets pairs of field and value ARGV[2:], with the ttl ARGV[1].
+var nativeSetScript = newScript(`
+local ttl = tonumber(ARGV[1])
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	redis.call('ZREM', KEYS[2], ARGV[i])
+	if ttl > 0 then
+		redis.call('HPEXPIRE', KEYS[1], ttl, 'FIELDS', 1, ARGV[i])
+	else
+		redis.call('HPERSIST', KEYS[1], 'FIELDS', 1, ARGV[i])
+	end
+end
+return 1
+`)
+
+// nativeExpireScript sets the ttl of field ARGV[1] at now ARGV[3] to ARGV[2], 0 to persist it or -1 to delete it.
+// It returns 1 if done, -1 if the field is missing or expired.
+var nativeExpireScript = newScript(hashLua + `
+if expired(ARGV[1], tonumber(ARGV[3])) then
+	return -1
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+local ttl = tonumber(ARGV[2])
+if ttl < 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+elseif ttl == 0 then
+	redis.call('HPERSIST', KEYS[1], 'FIELDS', 1, ARGV[1])
+else
+	redis.call('HPEXPIRE', KEYS[1], ttl, 'FIELDS', 1, ARGV[1])
+end
+return 1
+`)
+
+// nativeIncrScript runs HINCRBY or HINCRBYFLOAT ARGV[1] on field ARGV[2] by ARGV[3] at now ARGV[5],
+// and sets the ttl ARGV[4] only if the field is created.
+var nativeIncrScript = newScript(hashLua + `
+expired(ARGV[2], tonumber(ARGV[5]))
+local created = redis.call('HEXISTS', KEYS[1], ARGV[2]) == 0
+local v = redis.call(ARGV[1], KEYS[1], ARGV[2], ARGV[3])
+if created then
+	redis.call('ZREM', KEYS[2], ARGV[2])
+	if tonumber(ARGV[4]) > 0 then
+		redis.call('HPEXPIRE', KEYS[1], ARGV[4], 'FIELDS', 1, ARGV[2])
+	end
+end
+return v
+`)
+
+// nativeAliveScript returns the expire time of field ARGV[1] at now ARGV[2] in the timeout zset or an empty string,
+// and its ttl by HPTTL, -2 if it's missing or expired.
+var nativeAliveScript = newScript(hashLua + `
+if expired(ARGV[1], tonumber(ARGV[2])) then
+	return {'', -2}
+end
+local ms = redis.call('HPTTL', KEYS[1], 'FIELDS', 1, ARGV[1])[1]
+if ms == -2 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	return {'', -2}
+end
+return {redis.call('ZSCORE', KEYS[2], ARGV[1]) or '', ms}
+`)
+
+// nativeGetVersionScript returns the value of field ARGV[1] at now ARGV[3], the version ARGV[2] is given to it
+// if it has none, keeping the ttl.
+var nativeGetVersionScript = newScript(hashLua + envelopeLua + `
+if expired(ARGV[1], tonumber(ARGV[3])) then
+	return false
+end
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if not v then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	return false
+end
+if version(v) ~= '' then
+	return v
+end
+v = versioned(v, ARGV[2])
+local ttl = redis.call('HPTTL', KEYS[1], 'FIELDS', 1, ARGV[1])[1]
+redis.call('HSET', KEYS[1], ARGV[1], v)
+if ttl > 0 then
+	redis.call('HPEXPIRE', KEYS[1], ttl, 'FIELDS', 1, ARGV[1])
+end
+return v
+`)
+
+// nativeSetIfScript sets field ARGV[1] to ARGV[2] with the ttl ARGV[3] at now ARGV[6] on the condition ARGV[4]:
+// NX if missing, XX if existing, or CAS if the version of the value is ARGV[5].
+// It returns 1 if set, 0 if the field exists for NX or the version mismatches for CAS, -1 if the field is missing.
+var nativeSetIfScript = newScript(hashLua + envelopeLua + `
+expired(ARGV[1], tonumber(ARGV[6]))
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if ARGV[4] == 'NX' then
+	if cur then
+		return 0
+	end
+elseif not cur then
+	return -1
+elseif ARGV[4] == 'CAS' and version(cur) ~= ARGV[5] then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZREM', KEYS[2], ARGV[1])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('HPEXPIRE', KEYS[1], ARGV[3], 'FIELDS', 1, ARGV[1])
+else
+	redis.call('HPERSIST', KEYS[1], 'FIELDS', 1, ARGV[1])
+end
+return 1
+`)
+
+// migrateScript moves at most ARGV[2] fields from the timeout zset KEYS[2] to native field ttl at now ARGV[1]
+// in unix nano, the expired ones are deleted. It returns the number of fields moved.
+var migrateScript = newScript(`
+local now = tonumber(ARGV[1])
+local zs = redis.call('ZRANGE', KEYS[2], 0, tonumber(ARGV[2]) - 1, 'WITHSCORES')
+for i = 1, #zs, 2 do
+	local ttl = math.ceil((tonumber(zs[i + 1]) - now) / 1000000)
+	if ttl > 0 then
+		redis.call('HPEXPIRE', KEYS[1], ttl, 'FIELDS', 1, zs[i])
+	else
+		redis.call('HDEL', KEYS[1], zs[i])
+	end
+	redis.call('ZREM', KEYS[2], zs[i])
+end
+return #zs / 2
+`)
+
+// native tells whether fields expire natively, which is if NativeTTL is set and the server supports it.
+// The server is probed at the first call, errors not from redis, such as network errors, are returned
+// without settling the mode.
+func (c *hashCache) native(ctx context.Context) (bool, error) {
+	switch atomic.LoadInt32(&c.ttlMode) {
+	case ttlModeZSet:
+		return false, nil
+	case ttlModeNative:
+		return true, nil
+	}
+
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	if mode := atomic.LoadInt32(&c.ttlMode); mode != ttlModeUnknown {
+		return mode == ttlModeNative, nil
+	}
+	mode := ttlModeZSet
+	if c.NativeTTL {
+		ok, err := supportNativeTTL(ctx, c.rdb, c.keyName)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			mode = ttlModeNative
+		}
+	}
+	atomic.StoreInt32(&c.ttlMode, mode)
+	return mode == ttlModeNative, nil
+}
+
+// supportNativeTTL probes HPEXPIRE on a key which isn't expected to exist, so nothing is changed.
+// Only a reply of HPEXPIRE tells it's supported, and an unknown command tells it's not.
+// Other errors, such as NOPERM, READONLY or LOADING, are returned since they tell neither.
+func supportNativeTTL(ctx context.Context, rdb redis.UniversalClient, keyName string) (bool, error) {
+	v, err := rdb.DoContext(ctx, "HPEXPIRE", keyName+".probe", 1, "FIELDS", 1, "probe").Result()
+	if err != nil {
+		if notRedisError(err) {
+			return false, err
+		}
+		if strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			return false, nil
+		}
+		return false, cache.NewCacheError(err)
+	}
+	switch v.(type) {
+	case int64, []interface{}:
+		return true, nil
+	}
+	return false, nil
+}
+
+// MigrateToNativeTTL moves the expire times of fields from the timeout zset to native field ttl,
+// and returns the number of fields moved. cache.ErrUnsupported is returned if the server doesn't support it.
+// It should be called once all clients of the hash use native field ttl, fields written by the others
+// are kept in the zset till then.
+func (c *hashCache) MigrateToNativeTTL(ctx context.Context) (int, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	ok, err := supportNativeTTL(ctx, c.rdb, c.keyName)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, cache.ErrUnsupported
+	}
+
+	total := 0
+	for {
+		n, err := migrateScript.run(ctx, c.rdb, c.keys(), c.Clock.Now().UnixNano(), gcBatchSize).Int()
+		if err != nil {
+			return total, cache.NewCacheError(err)
+		}
+		total += n
+		if n < gcBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// nativeAlive is alive of native field ttl, the expire time is told by the timeout zset if the field is in it,
+// or by HPTTL from now of c.Clock.
+func (c *hashCache) nativeAlive(o *cache.Options, field string) (int64, error) {
+	now := c.Clock.Now()
+	ret, err := nativeAliveScript.run(o.Ctx, c.rdb, c.keys(), field, now.UnixNano()).Result()
+	if err != nil {
+		if notRedisError(err) {
+			return 0, err
+		}
+		return 0, cache.NewCacheError(err)
+	}
+	vals, ok := ret.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 0, cache.NewCacheError(errors.New("count not match"))
+	}
+	score, _ := vals[0].(string)
+	ms, ok := vals[1].(int64)
+	if !ok {
+		return 0, cache.NewCacheError(errors.New("type not match"))
+	}
+	switch {
+	case ms == -2:
+		return 0, cache.ErrNotFound
+	case score != "":
+		at, err := strconv.ParseFloat(score, 64)
+		if err != nil {
+			return 0, cache.NewCacheError(err)
+		}
+		return int64(at), nil
+	case ms < 0:
+		return 0, nil
+	}
+	return now.Add(time.Duration(ms) * time.Millisecond).UnixNano(), nil
+}
+
+// nativeTTL converts the expire time at in unix nano to the ttl of native scripts, 0 stays for none
+// and a negative one or a time passed is for deleting.
+func nativeTTL(now time.Time, at int64) int64 {
+	if at <= 0 {
+		return at
+	}
+	if d := time.Duration(at - now.UnixNano()); d > 0 {
+		return milliseconds(d)
+	}
+	return -1
+}
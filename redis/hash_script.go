@@ -0,0 +1,201 @@
+package redis
+
+// Scripts of hash cache, KEYS[1] is the hash of values and KEYS[2] is the timeout zset,
+// scored by the expire time in unix nano. Times are passed from the client, so that cache.Clock applies.
+
+// hashLua is the prefix of hash cache scripts. expired removes field if it's expired at now, and reports it.
+const hashLua = `
+local function expired(field, now)
+	local e = redis.call('ZSCORE', KEYS[2], field)
+	if e and tonumber(e) < now then
+		redis.call('HDEL', KEYS[1], field)
+		redis.call('ZREM', KEYS[2], field)
+		return true
+	end
+	return false
+end
+`
+
+// hashGetScript returns the value of field ARGV[1] at now ARGV[2], or nil if it's missing or expired.
+var hashGetScript = newScript(hashLua + `
+if expired(ARGV[1], tonumber(ARGV[2])) then
+	return false
+end
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if not v then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+end
+return v
+`)
+
+// hashMGetScript returns the values of fields ARGV[2:] at now ARGV[1], nil for the ones missing or expired.
+var hashMGetScript = newScript(hashLua + `
+local now = tonumber(ARGV[1])
+local ret = {}
+for i = 2, #ARGV do
+	local v = false
+	if not expired(ARGV[i], now) then
+		v = redis.call('HGET', KEYS[1], ARGV[i])
+	end
+	ret[i - 1] = v
+end
+return ret
+`)
+
+// hashSetScript sets pairs of field and value ARGV[2:], with the expire time ARGV[1], 0 for none.
+var hashSetScript = newScript(`
+local expire = ARGV[1]
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	if tonumber(expire) > 0 then
+		redis.call('ZADD', KEYS[2], expire, ARGV[i])
+	else
+		redis.call('ZREM', KEYS[2], ARGV[i])
+	end
+end
+return 1
+`)
+
+// hashAliveScript returns the expire time of field ARGV[1] at now ARGV[2] as a string, '0' if it never expires,
+// or nil if it's missing or expired.
+var hashAliveScript = newScript(hashLua + `
+if expired(ARGV[1], tonumber(ARGV[2])) then
+	return false
+end
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	return false
+end
+return redis.call('ZSCORE', KEYS[2], ARGV[1]) or '0'
+`)
+
+// hashExpireScript sets the expire time of field ARGV[1] at now ARGV[2] to ARGV[3], 0 to persist it
+// or -1 to delete it. It returns 1 if done, -1 if the field is missing or expired.
+var hashExpireScript = newScript(hashLua + `
+if expired(ARGV[1], tonumber(ARGV[2])) then
+	return -1
+end
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	return -1
+end
+local at = tonumber(ARGV[3])
+if at < 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	redis.call('ZREM', KEYS[2], ARGV[1])
+elseif at == 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+else
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+end
+return 1
+`)
+
+// hashDelScript deletes fields ARGV, and returns the number of fields deleted.
+var hashDelScript = newScript(`
+local n = 0
+for i = 1, #ARGV do
+	n = n + redis.call('HDEL', KEYS[1], ARGV[i])
+	redis.call('ZREM', KEYS[2], ARGV[i])
+end
+return n
+`)
+
+// hashGCScript removes at most ARGV[2] fields expired at now ARGV[1], and returns the number of them.
+var hashGCScript = newScript(`
+local fields = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, field in ipairs(fields) do
+	redis.call('HDEL', KEYS[1], field)
+	redis.call('ZREM', KEYS[2], field)
+end
+return #fields
+`)
+
+// hashSweepScript samples at most ARGV[2] fields which expire the earliest, removes the ones expired
+// at now ARGV[1], and returns the number of fields sampled and removed.
+var hashSweepScript = newScript(`
+local now = tonumber(ARGV[1])
+local zs = redis.call('ZRANGE', KEYS[2], 0, tonumber(ARGV[2]) - 1, 'WITHSCORES')
+local removed = 0
+for i = 1, #zs, 2 do
+	if tonumber(zs[i + 1]) < now then
+		redis.call('HDEL', KEYS[1], zs[i])
+		redis.call('ZREM', KEYS[2], zs[i])
+		removed = removed + 1
+	end
+end
+return {#zs / 2, removed}
+`)
+
+// hashIncrScript runs HINCRBY or HINCRBYFLOAT ARGV[1] on field ARGV[2] by ARGV[3] at now ARGV[4].
+// The timeout zset is updated only if the field is created, with the expire time ARGV[5] or 0 for none.
+var hashIncrScript = newScript(hashLua + `
+expired(ARGV[2], tonumber(ARGV[4]))
+local created = redis.call('HEXISTS', KEYS[1], ARGV[2]) == 0
+local v = redis.call(ARGV[1], KEYS[1], ARGV[2], ARGV[3])
+if created then
+	if tonumber(ARGV[5]) > 0 then
+		redis.call('ZADD', KEYS[2], ARGV[5], ARGV[2])
+	else
+		redis.call('ZREM', KEYS[2], ARGV[2])
+	end
+end
+return v
+`)
+
+// hashGetVersionScript is hashGetScript, the version ARGV[3] is given to the value if it has none.
+var hashGetVersionScript = newScript(hashLua + envelopeLua + `
+if expired(ARGV[1], tonumber(ARGV[2])) then
+	return false
+end
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if not v then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	return false
+end
+if version(v) == '' then
+	v = versioned(v, ARGV[3])
+	redis.call('HSET', KEYS[1], ARGV[1], v)
+end
+return v
+`)
+
+// hashSetIfScript sets field ARGV[1] to ARGV[2] at now ARGV[3] on the condition ARGV[5]: NX if missing,
+// XX if existing, or CAS if the version of the value is ARGV[6]. The timeout zset is updated with
+// the expire time ARGV[4], or 0 for none.
+// It returns 1 if set, 0 if the field exists for NX or the version mismatches for CAS, -1 if the field is missing.
+var hashSetIfScript = newScript(hashLua + envelopeLua + `
+expired(ARGV[1], tonumber(ARGV[3]))
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if ARGV[5] == 'NX' then
+	if cur then
+		return 0
+	end
+elseif not cur then
+	return -1
+elseif ARGV[5] == 'CAS' and version(cur) ~= ARGV[6] then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+else
+	redis.call('ZREM', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// hashScanScript runs HSCAN from cursor ARGV[1] with MATCH ARGV[2] and COUNT ARGV[3], and filters out the fields
+// expired at now ARGV[4]. It returns the next cursor and the fields, as HSCAN does.
+var hashScanScript = newScript(`
+local ret = redis.call('HSCAN', KEYS[1], ARGV[1], 'MATCH', ARGV[2], 'COUNT', ARGV[3])
+local now = tonumber(ARGV[4])
+local fields = {}
+for i = 1, #ret[2], 2 do
+	local e = redis.call('ZSCORE', KEYS[2], ret[2][i])
+	if not e or tonumber(e) >= now then
+		fields[#fields + 1] = ret[2][i]
+	end
+end
+return {ret[1], fields}
+`)
@@ -1,7 +1,9 @@
 package redis
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -55,6 +57,41 @@ func Test_hashStoreGet(t *testing.T) {
 	}
 }
 
+func Test_hashStoreGetStale(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := NewHashCache(rdb, json.NewCodec(), "hash_stale_test", nil)
+
+	c.Set("early", 1, cache.WithTTL(time.Minute), cache.WithRecomputeTime(time.Hour))
+	c.Set("stale", 2, cache.WithTTL(time.Second), cache.WithStaleTTL(time.Minute))
+
+	stale := 0
+	for i := 0; i < 10; i++ {
+		v, ok, err := c.GetStale("early", cache.WithEarlyExpiration(1))
+		assert.NoError(t, err)
+		var val int
+		assert.NoError(t, c.Codec().DecodeTo(v, &val))
+		assert.Equal(t, 1, val)
+		if ok {
+			stale++
+		}
+	}
+	assert.True(t, stale > 0)
+
+	_, ok, err := c.GetStale("early")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	time.Sleep(1500 * time.Millisecond) // wait for soft expires
+
+	_, ok, err = c.GetStale("stale")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
 func Test_hashStoreSet(t *testing.T) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     "localhost:6379", // use default Addr
@@ -76,10 +113,56 @@ func Test_hashStoreSet(t *testing.T) {
 }
 
 func Test_hashStoreMGet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := NewHashCache(rdb, json.NewCodec(), "hash_mget_test", nil)
+	assert.NoError(t, c.Clear())
 
+	n := 10
+	keys := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			c.Set(i, i)
+		}
+		keys = append(keys, i)
+	}
+
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, n/2, len(ret))
+	for i := 0; i < n; i += 2 {
+		var val int
+		err = c.Codec().DecodeTo(ret[i], &val)
+		assert.NoError(t, err)
+		assert.Equal(t, i, val)
+	}
 }
 
 func Test_hashStoreMSet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := cache.NewTypedCache[int, string](NewHashCache(rdb, json.NewCodec(), "hash_mset_test", nil))
+	assert.NoError(t, c.Clear())
+
+	n := 10
+	kvs := make(map[int]string)
+	keys := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		kvs[i] = fmt.Sprintf("value of %d", i)
+		keys = append(keys, i)
+	}
+	err := c.MSet(kvs, cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, kvs, ret)
 }
 
 func Test_hashStoreExists(t *testing.T) {
@@ -170,3 +253,504 @@ func Test_hashStoreClear(t *testing.T) {
 		assert.False(t, ok)
 	}
 }
+
+func Test_hashStoreSweeper(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Sweeper: &cache.SweeperConfig{
+		SampleSize: 20,
+		Threshold:  0.25,
+	}})
+	c.Clear()
+
+	n := 100
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			c.Set(i, i, cache.WithTTL(time.Hour))
+		} else {
+			c.Set(i, i, cache.WithTTL(100*time.Millisecond))
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// Interval is 0, sweep manually
+	c.sweeper.Sweep()
+	assert.Equal(t, int64(n/10), rdb.HLen("hash_test").Val())
+	assert.Equal(t, int64(n/10), rdb.ZCard("hash_test.timeout").Val())
+	stats := c.SweeperStats()
+	assert.Equal(t, uint64(n-n/10), stats.Removed)
+	assert.Equal(t, uint64(6), stats.Rounds)
+}
+
+func Test_hashStoreClock(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{GCInterval: time.Minute, Clock: clock})
+	defer c.Close(context.Background())
+	c.Clear()
+
+	c.Set("short", 1, cache.WithTTL(time.Second))
+	c.Set("long", 2, cache.WithTTL(time.Hour))
+	c.Set("gc", 3, cache.WithTTL(time.Second))
+
+	clock.Advance(2 * time.Second)
+	_, err := c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	ok, err := c.Exists("long")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// gc scheduled by the clock
+	assert.True(t, rdb.HExists("hash_test", "gc").Val())
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		return !rdb.HExists("hash_test", "gc").Val()
+	}, time.Second, time.Millisecond)
+}
+
+func Test_hashStoreExpirer(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Clock: clock})
+	c.Clear()
+
+	c.Set("ttl", 1, cache.WithTTL(time.Minute))
+	c.Set("no ttl", 2)
+
+	ttl, err := c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Millisecond))
+	ttl, err = c.TTL("no ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = c.TTL("missing")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, cache.ErrUnsupported, c.Touch("ttl"))
+	assert.NoError(t, c.Touch("ttl", cache.WithTTL(time.Minute)))
+	ttl, _ = c.TTL("ttl")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Millisecond))
+
+	assert.NoError(t, c.Expire("no ttl", time.Second))
+	clock.Advance(2 * time.Second)
+	_, err = c.Get("no ttl")
+	assert.Equal(t, cache.ErrNotFound, err)
+	assert.Equal(t, cache.ErrNotFound, c.Expire("no ttl", time.Second))
+
+	assert.NoError(t, c.Persist("ttl"))
+	clock.Advance(time.Hour)
+	ttl, err = c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	assert.NoError(t, c.Expire("ttl", 0))
+	ok, _ := c.Exists("ttl")
+	assert.False(t, ok)
+	assert.Equal(t, cache.ErrNotFound, c.Persist("ttl"))
+}
+
+func Test_hashStoreCounter(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Clock: clock})
+	c.Clear()
+
+	v, err := c.Incr("cnt", cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	v, err = c.IncrBy("cnt", 10, cache.WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), v)
+	ttl, _ := c.TTL("cnt")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Millisecond))
+
+	// an expired counter restarts from delta without ttl
+	clock.Advance(2 * time.Minute)
+	v, err = c.Decr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), v)
+	ttl, _ = c.TTL("cnt")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	f, err := c.IncrByFloat("cnt", 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, -0.5, f)
+
+	c.Set("str", "a")
+	_, err = c.Incr("str")
+	assert.Equal(t, cache.ErrTypeMismatch, err)
+
+	// a counter set with a ttl can be incremented
+	c.Set("cnt", 5, cache.WithTTL(time.Minute))
+	v, err = c.Incr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), v)
+}
+
+func Test_hashCacheKeys(t *testing.T) {
+	assert.False(t, hasHashTag("hash"))
+	assert.False(t, hasHashTag("{}:hash"))
+	assert.True(t, hasHashTag("{app}:hash"))
+
+	for _, c := range []struct {
+		keyName string
+		tag     bool
+		keys    []string
+	}{
+		{"hash", false, []string{"hash", "hash.timeout"}},
+		{"hash", true, []string{"hash", "{hash}.timeout"}},
+		{"{app}:hash", true, []string{"{app}:hash", "{app}:hash.timeout"}},
+	} {
+		hc := NewHashCache(nil, json.NewCodec(), c.keyName, &HashCacheConfig{GCInterval: time.Hour, HashTagTimeout: c.tag})
+		assert.Equal(t, c.keys, hc.keys())
+		hc.Close(context.Background())
+	}
+}
+
+func Test_hashStoreConditionalSet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Clock: clock})
+	c.Clear()
+
+	assert.Equal(t, cache.ErrNotFound, c.Replace("a", 1))
+	assert.NoError(t, c.Add("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrExisted, c.Add("a", 2))
+	assert.NoError(t, c.Replace("a", 3))
+	ttl, _ := c.TTL("a")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	var got int
+	v, ver, err := c.GetWithVersion("a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Codec().DecodeTo(v, &got))
+	assert.Equal(t, 3, got)
+	assert.NoError(t, c.CompareAndSwap("a", 4, ver, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+
+	// the version is kept by reads and changed by writes, even of the same value
+	_, ver, _ = c.GetWithVersion("a")
+	_, again, _ := c.GetWithVersion("a")
+	assert.Equal(t, ver, again)
+	ttl, _ = c.TTL("a")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Millisecond))
+	c.Set("a", 4, cache.WithTTL(time.Minute))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+	_, ver, _ = c.GetWithVersion("a")
+	assert.NoError(t, c.CompareAndSwap("a", 4, ver, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 4, ver))
+
+	// expired fields can be added
+	_, ver, _ = c.GetWithVersion("a")
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, cache.ErrNotFound, c.CompareAndSwap("a", 5, ver))
+	assert.NoError(t, c.Add("a", 6))
+	ttl, _ = c.TTL("a")
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func Test_hashStoreSetWithoutTTL(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", &HashCacheConfig{Clock: clock})
+	c.Clear()
+
+	// re-set without ttl never expires
+	c.Set("a", 1, cache.WithTTL(time.Second))
+	c.MSet(map[interface{}]interface{}{"b": 2}, cache.WithTTL(time.Second))
+	c.Set("a", 1)
+	c.MSet(map[interface{}]interface{}{"b": 2})
+	clock.Advance(time.Minute)
+	ret, err := c.MGet([]interface{}{"a", "b"})
+	assert.NoError(t, err)
+	assert.Len(t, ret, 2)
+	assert.Equal(t, int64(0), rdb.ZCard("hash_test.timeout").Val())
+
+	// GC removes the expired ones
+	c.Set("c", 3, cache.WithTTL(time.Second))
+	clock.Advance(time.Minute)
+	c.GC()
+	assert.False(t, rdb.HExists("hash_test", "c").Val())
+	assert.Equal(t, int64(0), rdb.ZCard("hash_test.timeout").Val())
+}
+
+func Test_hashStoreConcurrent(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewHashCache(rdb, json.NewCodec(), "hash_test", nil)
+	c.Clear()
+
+	// values and their expiry are written together, a reader never sees a value with the expiry of another
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				// even values are set with a ttl, odd ones without
+				if i%2 == 0 {
+					c.Set("a", 2*j, cache.WithTTL(time.Hour))
+				} else {
+					c.Set("a", 2*j+1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	ok, err := c.Exists("a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	var got int
+	ret, _ := c.Get("a")
+	assert.NoError(t, c.Codec().DecodeTo(ret, &got))
+	hasTTL := got%2 == 0
+	assert.Equal(t, hasTTL, rdb.ZScore("hash_test.timeout", "a").Err() == nil)
+}
+
+func Test_hashStoreNativeTTL(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	if ok, err := supportNativeTTL(context.Background(), rdb, "hash_native_test"); err != nil || !ok {
+		t.Skip("native field ttl is not supported")
+	}
+	c := NewHashCache(rdb, json.NewCodec(), "hash_native_test", &HashCacheConfig{NativeTTL: true})
+	defer c.Close(context.Background())
+	c.Clear()
+
+	c.Set("ttl", 1, cache.WithTTL(time.Minute))
+	c.Set("no ttl", 2)
+	assert.Equal(t, int64(0), rdb.Exists("hash_native_test.timeout").Val())
+	ttl, err := c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(2*time.Second))
+	ttl, err = c.TTL("no ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	assert.NoError(t, c.Persist("ttl"))
+	ttl, _ = c.TTL("ttl")
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.NoError(t, c.Expire("no ttl", time.Hour))
+	ttl, _ = c.TTL("no ttl")
+	assert.InDelta(t, float64(time.Hour), float64(ttl), float64(2*time.Second))
+	assert.NoError(t, c.Touch("no ttl", cache.WithTTL(time.Minute)))
+	ttl, _ = c.TTL("no ttl")
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(2*time.Second))
+
+	v, err := c.Incr("cnt", cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	ttl, _ = c.TTL("cnt")
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(2*time.Second))
+	assert.Equal(t, cache.ErrExisted, c.Add("cnt", 1))
+
+	// versioning a field keeps its ttl
+	_, ver, err := c.GetWithVersion("ttl")
+	assert.NoError(t, err)
+	assert.NoError(t, c.CompareAndSwap("ttl", 2, ver, cache.WithTTL(time.Minute)))
+	_, ver, _ = c.GetWithVersion("no ttl")
+	ttl, _ = c.TTL("no ttl")
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(2*time.Second))
+	c.Set("no ttl", 2)
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("no ttl", 3, ver))
+
+	// fields expire by the server
+	c.Set("short", 1, cache.WithTTL(time.Second))
+	time.Sleep(2100 * time.Millisecond)
+	_, err = c.Get("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+	_, err = c.TTL("short")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
+func Test_hashStoreNativeTTLProbeError(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	if ok, err := supportNativeTTL(context.Background(), rdb, "hash_probe_test"); err != nil || !ok {
+		t.Skip("native field ttl is not supported")
+	}
+	c := NewHashCache(rdb, json.NewCodec(), "hash_probe_test", &HashCacheConfig{NativeTTL: true})
+	defer c.Close(context.Background())
+	c.Clear()
+
+	// a probe key of the wrong type makes HPEXPIRE fail with neither a reply nor an unknown command
+	rdb.Set("hash_probe_test.probe", 1, 0)
+	ok, err := supportNativeTTL(context.Background(), rdb, "hash_probe_test")
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Error(t, c.Set("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, ttlModeUnknown, c.ttlMode)
+
+	// the mode is settled once the probe succeeds
+	rdb.Del("hash_probe_test.probe")
+	assert.NoError(t, c.Set("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, ttlModeNative, c.ttlMode)
+}
+
+func Test_hashStoreMigrateToNativeTTL(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	if ok, err := supportNativeTTL(context.Background(), rdb, "hash_migrate_test"); err != nil || !ok {
+		t.Skip("native field ttl is not supported")
+	}
+	clock := cache.NewFakeClock(time.Now())
+	zc := NewHashCache(rdb, json.NewCodec(), "hash_migrate_test", &HashCacheConfig{Clock: clock})
+	zc.Clear()
+	zc.Set("ttl", 1, cache.WithTTL(time.Minute))
+	zc.Set("expired", 2, cache.WithTTL(time.Second))
+	zc.Set("no ttl", 3)
+	clock.Advance(2 * time.Second)
+
+	c := NewHashCache(rdb, json.NewCodec(), "hash_migrate_test", &HashCacheConfig{Clock: clock, NativeTTL: true})
+	n, err := c.MigrateToNativeTTL(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, int64(0), rdb.Exists("hash_migrate_test.timeout").Val())
+	assert.False(t, rdb.HExists("hash_migrate_test", "expired").Val())
+
+	ttl, err := c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(58*time.Second), float64(ttl), float64(2*time.Second))
+	ttl, err = c.TTL("no ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func Test_hashStoreMixedTTL(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	if ok, err := supportNativeTTL(context.Background(), rdb, "hash_mixed_test"); err != nil || !ok {
+		t.Skip("native field ttl is not supported")
+	}
+	clock := cache.NewFakeClock(time.Now())
+	zc := NewHashCache(rdb, json.NewCodec(), "hash_mixed_test", &HashCacheConfig{Clock: clock})
+	defer zc.Close(context.Background())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_mixed_test", &HashCacheConfig{Clock: clock, NativeTTL: true})
+	defer c.Close(context.Background())
+	zc.Clear()
+
+	// fields written with the timeout zset expire for native clients as well
+	zc.Set("a", 1, cache.WithTTL(time.Minute))
+	zc.Set("b", 2, cache.WithTTL(time.Minute))
+	zc.Set("c", 3, cache.WithTTL(time.Minute))
+	zc.Set("d", 4, cache.WithTTL(time.Minute))
+	ttl, err := c.TTL("a")
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(time.Minute), float64(ttl), float64(time.Millisecond))
+
+	// a native write takes the field out of the zset
+	c.Set("b", 5)
+	assert.Equal(t, redis.Nil, rdb.ZScore("hash_mixed_test.timeout", "b").Err())
+	assert.NoError(t, c.Expire("c", time.Hour))
+	assert.Equal(t, redis.Nil, rdb.ZScore("hash_mixed_test.timeout", "c").Err())
+
+	clock.Advance(2 * time.Minute)
+	_, err = c.Get("a")
+	assert.Equal(t, cache.ErrNotFound, err)
+	ok, err := c.Exists("d")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ret, err := c.MGet([]interface{}{"a", "b", "c", "d"})
+	assert.NoError(t, err)
+	assert.Len(t, ret, 2)
+	ttl, err = zc.TTL("b")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	var keys []string
+	var cursor uint64
+	for {
+		ks, next, err := c.Scan(context.Background(), cursor, "*", 10)
+		assert.NoError(t, err)
+		keys = append(keys, ks...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.ElementsMatch(t, []string{"b", "c"}, keys)
+}
+
+func Test_hashStoreScan(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	for _, native := range []bool{false, true} {
+		c := NewHashCache(rdb, json.NewCodec(), "hash_scan_test", &HashCacheConfig{Clock: clock, NativeTTL: native})
+		c.Clear()
+		c.Set("a1", 1)
+		c.Set("a2", 2)
+		c.Set("b1", 3)
+		if !native {
+			c.Set("a3", 4, cache.WithTTL(time.Second))
+			clock.Advance(time.Minute)
+		}
+
+		var keys []string
+		var cursor uint64
+		for {
+			ks, next, err := c.Scan(context.Background(), cursor, "a*", 1)
+			assert.NoError(t, err)
+			keys = append(keys, ks...)
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+		assert.ElementsMatch(t, []string{"a1", "a2"}, keys)
+		c.Close(context.Background())
+	}
+}
+
+func Test_hashStoreStats(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	c := NewHashCache(rdb, json.NewCodec(), "hash_stats_test", &HashCacheConfig{Clock: clock})
+	defer c.Close(context.Background())
+	c.Clear()
+
+	c.Set("a", 1)
+	c.MSet(map[interface{}]interface{}{"b": 2, "c": 3}, cache.WithTTL(time.Second))
+	c.Get("a")
+	c.Get("x")
+	c.MGet([]interface{}{"a", "y"})
+	c.Delete("a")
+	c.Delete("a")
+	clock.Advance(time.Minute)
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), s.Entries)
+	assert.Equal(t, int64(2), s.Expired)
+	assert.True(t, s.Bytes != 0)
+	assert.Equal(t, uint64(2), s.Hits)
+	assert.Equal(t, uint64(2), s.Misses)
+	assert.Equal(t, uint64(3), s.Sets)
+	assert.Equal(t, uint64(1), s.Deletes)
+
+	c.GC()
+	s, _ = c.Stats()
+	assert.Equal(t, int64(0), s.Entries)
+	assert.Equal(t, uint64(2), s.Expirations)
+	assert.Equal(t, uint64(1), s.GCRuns)
+}
@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// script is a lua script run by EVALSHA, and by EVAL if the server doesn't have it cached yet.
+type script struct {
+	src  string
+	hash string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	return &script{
+		src:  src,
+		hash: hex.EncodeToString(sum[:]),
+	}
+}
+
+func (s *script) run(ctx context.Context, rdb redis.UniversalClient, keys []string, args ...interface{}) *redis.Cmd {
+	argv := make([]interface{}, 0, 3+len(keys)+len(args))
+	argv = append(argv, "EVALSHA", s.hash, len(keys))
+	for _, key := range keys {
+		argv = append(argv, key)
+	}
+	argv = append(argv, args...)
+
+	cmd := rdb.DoContext(ctx, argv...)
+	if err := cmd.Err(); err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		argv[0] = "EVAL"
+		argv[1] = s.src
+		cmd = rdb.DoContext(ctx, argv...)
+	}
+	return cmd
+}
@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scriptRun(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	s := newScript(`return ARGV[1] .. KEYS[1]`)
+
+	// loaded by EVAL at first, then run by EVALSHA
+	assert.NoError(t, rdb.ScriptFlush().Err())
+	v, err := s.run(context.TODO(), rdb, []string{"b"}, "a").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", v)
+	ok, err := rdb.ScriptExists(s.hash).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true}, ok)
+	v, err = s.run(context.TODO(), rdb, []string{"b"}, "a").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", v)
+}
@@ -1,52 +1,188 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v7"
 	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/internal/lifecycle"
 )
 
 type Client = redis.UniversalClient
 
 var _ cache.Cache = (*stringCache)(nil)
+var _ cache.StaleGetter = (*stringCache)(nil)
+var _ cache.Closer = (*stringCache)(nil)
+var _ cache.Expirer = (*stringCache)(nil)
+var _ cache.Counter = (*stringCache)(nil)
+var _ cache.ConditionalSetter = (*stringCache)(nil)
+var _ cache.Scanner = (*stringCache)(nil)
+var _ cache.StatsReporter = (*stringCache)(nil)
+
+// incrScript runs INCRBY or INCRBYFLOAT, and sets the ttl in milliseconds if the key is created.
+var incrScript = newScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local v = redis.call(ARGV[1], KEYS[1], ARGV[2])
+if created and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return v
+`)
+
+// getVersionScript returns the value of the key, the version ARGV[1] is given to it if it has none,
+// keeping the ttl.
+var getVersionScript = newScript(envelopeLua + `
+local v = redis.call('GET', KEYS[1])
+if not v or version(v) ~= '' then
+	return v
+end
+v = versioned(v, ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], v, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], v)
+end
+return v
+`)
+
+// casScript sets the key to ARGV[1] with the ttl ARGV[3] in milliseconds, only if the version of the value is ARGV[2].
+// It returns 1 if set, 0 if the version mismatches, -1 if the key is missing.
+var casScript = newScript(envelopeLua + `
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+	return -1
+end
+if version(cur) ~= ARGV[2] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+type StringCacheConfig struct {
+	// Prefix is the namespace of keys, prepended to the ones made by KeyStringFunc.
+	// Clear removes the keys with the prefix, and is unsupported without it.
+	Prefix string
+	// KeyStringFunc makes the redis key of a key string, which is used as is if nil.
+	KeyStringFunc func(key string) string
+	// ScanCount is the COUNT hint of SCAN, and the most keys unlinked at once by Clear.
+	ScanCount int
+	// Generation embeds the generation of Prefix in keys, which is kept in the key Prefix+"generation",
+	// so Clear increments it in O(1) instead of scanning. Keys of old generations age out by their ttl.
+	Generation bool
+	// GenerationCacheTTL is how long the generation is cached locally, a Clear by other clients
+	// is seen after it at most.
+	GenerationCacheTTL time.Duration
+	// Clock tells the time for soft expiry and the generation cached, cache.RealClock is used if nil.
+	// Ttl goes by the time of redis server instead.
+	Clock cache.Clock
+}
+
+var DefaultStringCacheConfig = StringCacheConfig{
+	ScanCount:          1000,
+	GenerationCacheTTL: time.Second,
+}
 
 type stringCache struct {
-	codec         cache.Codec
-	rdb           redis.UniversalClient
-	keyStringFunc func(key string) string
+	codec cache.Codec
+	rdb   redis.UniversalClient
+	StringCacheConfig
+	lc    *lifecycle.Lifecycle
+	gen   generation
+	stats cache.StatsCounter
 }
 
 func NewStringCache(c redis.UniversalClient, codec cache.Codec, keyStringFunc func(key string) string) *stringCache {
-	return &stringCache{
-		rdb:           c,
-		codec:         codec,
-		keyStringFunc: keyStringFunc,
+	cfg := DefaultStringCacheConfig
+	cfg.KeyStringFunc = keyStringFunc
+	return NewStringCacheWithConfig(c, codec, &cfg)
+}
+
+func NewStringCacheWithConfig(c redis.UniversalClient, codec cache.Codec, cfg *StringCacheConfig) *stringCache {
+	sc := &stringCache{
+		rdb:               c,
+		codec:             codec,
+		StringCacheConfig: DefaultStringCacheConfig,
+		lc:                lifecycle.New(),
+	}
+	if cfg != nil {
+		sc.StringCacheConfig = *cfg
+	}
+	if sc.ScanCount <= 0 {
+		sc.ScanCount = DefaultStringCacheConfig.ScanCount
+	}
+	if sc.GenerationCacheTTL <= 0 {
+		sc.GenerationCacheTTL = DefaultStringCacheConfig.GenerationCacheTTL
 	}
+	if sc.Clock == nil {
+		sc.Clock = cache.RealClock
+	}
+	return sc
+}
+
+// Close makes later operations return cache.ErrClosed, the redis client is not closed.
+func (c *stringCache) Close(ctx context.Context) error {
+	return c.lc.Close(ctx)
 }
 
 func (c *stringCache) Get(key interface{}, options ...cache.Option) (interface{}, error) {
+	v, _, err := c.GetStale(key, options...)
+	return v, err
+}
+
+// GetStale is like Get, but also reports whether the value is past its soft expiry.
+func (c *stringCache) GetStale(key interface{}, options ...cache.Option) (interface{}, bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, false, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	keyStr := c.keyString(key)
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return nil, false, err
+	}
 
 	ret, err := c.rdb.DoContext(o.Ctx, "GET", keyStr).String()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, cache.ErrNotFound
+			c.stats.Hit(false)
+			return nil, false, cache.ErrNotFound
 		}
-		return nil, cache.NewCacheError(err)
+		return nil, false, cache.NewCacheError(err)
+	}
+	c.stats.Hit(true)
+	e := decodeEnvelope([]byte(ret))
+	v, err := c.codec.Decode(e.value)
+	if err != nil {
+		return nil, false, err
 	}
-	return c.codec.Decode([]byte(ret))
+	return v, e.isStale(c.Clock.Now(), o.Beta), nil
 }
 
 func (c *stringCache) Set(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	keyStr := c.keyString(key)
-	b, err := c.codec.Encode(value)
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	b, err := encodeValue(c.codec, value, &o, c.Clock.Now())
 	if err != nil {
 		return err
 	}
@@ -55,33 +191,41 @@ func (c *stringCache) Set(key, value interface{}, options ...cache.Option) error
 	args[0] = "SET"
 	args[1] = keyStr
 	args[2] = b
-	if o.TTL > 0 {
-		if usePrecise(o.TTL) {
-			args = append(args, "PX", int64(o.TTL/time.Millisecond))
-		} else {
-			args = append(args, "EX", int64(o.TTL/time.Second))
-		}
-	}
+	args = append(args, expireArgs(o.HardTTL())...)
 	if err := c.rdb.DoContext(o.Ctx, args...).Err(); err != nil {
 		return cache.NewCacheError(err)
 	}
+	c.stats.AddSets(1)
+
 	return nil
 }
 
 func (c *stringCache) MGet(keys []interface{}, options ...cache.Option) (map[interface{}]interface{}, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
 	args := make([]interface{}, 1, len(keys)+1)
 	args[0] = "MGET"
 	for _, key := range keys {
-		args = append(args, c.keyString(key))
+		keyStr, err := c.keyString(&o, key)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, keyStr)
 	}
 
-	vals, err := redis.NewSliceCmd(c.rdb.DoContext(o.Ctx, args).Result()).Result()
+	res, err := c.rdb.DoContext(o.Ctx, args...).Result()
 	if err != nil {
 		return nil, cache.NewCacheError(err)
 	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != len(keys) {
+		return nil, cache.NewCacheError(errors.New("count not match"))
+	}
 
 	ret := make(map[interface{}]interface{})
 	for i, val := range vals {
@@ -90,34 +234,38 @@ func (c *stringCache) MGet(keys []interface{}, options ...cache.Option) (map[int
 			if !ok {
 				continue
 			}
-			v, err := c.codec.Decode([]byte(str))
+			e := decodeEnvelope([]byte(str))
+			v, err := c.codec.Decode(e.value)
 			if err != nil {
 				continue
 			}
 			ret[keys[i]] = v
 		}
 	}
+	c.stats.AddHits(len(ret))
+	c.stats.AddMisses(len(keys) - len(ret))
 
 	return ret, nil
 }
 
 func (c *stringCache) MSet(keyValues map[interface{}]interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
 
-	ttlArgs := make([]interface{}, 0, 2)
-	if o.TTL > 0 {
-		if usePrecise(o.TTL) {
-			ttlArgs = append(ttlArgs, "PX", int64(o.TTL/time.Millisecond))
-		} else {
-			ttlArgs = append(ttlArgs, "EX", int64(o.TTL/time.Second))
-		}
-	}
+	ttlArgs := expireArgs(o.HardTTL())
+	now := c.Clock.Now()
 
 	pipeline := c.rdb.Pipeline()
 	for k, v := range keyValues {
-		keyStr := c.keyString(k)
-		b, err := c.codec.Encode(v)
+		keyStr, err := c.keyString(&o, k)
+		if err != nil {
+			return err
+		}
+		b, err := encodeValue(c.codec, v, &o, now)
 		if err != nil {
 			return err
 		}
@@ -128,40 +276,415 @@ func (c *stringCache) MSet(keyValues map[interface{}]interface{}, options ...cac
 		if len(ttlArgs) > 0 {
 			args = append(args, ttlArgs...)
 		}
-		pipeline.Do(args)
+		pipeline.Do(args...)
 	}
 
 	if _, err := pipeline.ExecContext(o.Ctx); err != nil {
 		return cache.NewCacheError(err)
 	}
+	c.stats.AddSets(len(keyValues))
 
 	return nil
 }
 
 func (c *stringCache) Exists(key interface{}, options ...cache.Option) (bool, error) {
+	if err := c.lc.Err(); err != nil {
+		return false, err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
-	return c.rdb.DoContext(o.Ctx, "EXISTS", c.keyString(key)).Bool()
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return false, err
+	}
+	return c.rdb.DoContext(o.Ctx, "EXISTS", keyStr).Bool()
 }
 
 func (c *stringCache) Delete(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
 	var o cache.Options
 	o.Apply(options...)
-	return c.rdb.DoContext(o.Ctx, "DEL", c.keyString(key)).Err()
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	n, err := c.rdb.DoContext(o.Ctx, "DEL", keyStr).Int()
+	if err != nil {
+		return err
+	}
+	c.stats.AddDeletes(n)
+	return nil
 }
 
+// Clear removes the keys with Prefix, cache.ErrUnsupported is returned if Prefix is empty.
 func (c *stringCache) Clear(options ...cache.Option) error {
-	return cache.ErrUnsupported
+	_, err := c.ClearCount(options...)
+	return err
+}
+
+// ClearCount is like Clear, and returns the number of keys removed. Keys are found by SCAN and removed
+// by UNLINK in batches of ScanCount, on every master in cluster mode. It stops once the context is done.
+// With Generation, it only increments the generation and returns 0.
+func (c *stringCache) ClearCount(options ...cache.Option) (int, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+	if c.Prefix == "" {
+		return 0, cache.ErrUnsupported
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+	if c.Generation {
+		return 0, c.incrGeneration(&o)
+	}
+
+	var total int64
+	err := forEachMaster(c.rdb, func(client *redis.Client) error {
+		n, err := unlinkMatch(o.Ctx, client, escapeGlob(c.Prefix)+"*", c.ScanCount)
+		atomic.AddInt64(&total, int64(n))
+		return err
+	})
+	c.stats.AddDeletes(int(total))
+	if err != nil {
+		if notRedisError(err) || err == cache.ErrUnsupported {
+			return int(total), err
+		}
+		return int(total), cache.NewCacheError(err)
+	}
+	return int(total), nil
+}
+
+// Scan runs SCAN over the keys with Prefix, in the current generation if Generation is enabled,
+// and returns the keys without the prefix. cache.ErrUnsupported is returned in cluster mode,
+// since a cursor only walks one node.
+func (c *stringCache) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
+	}
+	if _, ok := c.rdb.(*redis.ClusterClient); ok {
+		return nil, 0, cache.ErrUnsupported
+	}
+
+	o := cache.Options{Ctx: ctx}
+	prefix, err := c.keyPrefix(&o)
+	if err != nil {
+		return nil, 0, err
+	}
+	if match == "" {
+		match = "*"
+	}
+	args := []interface{}{"SCAN", cursor, "MATCH", escapeGlob(prefix) + match}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	keys, next, err := scanResult(c.rdb.DoContext(ctx, args...).Result())
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+	return keys, next, nil
+}
+
+// Stats returns the counters of this client, sizes are unknown since keys are spread over the keyspace.
+func (c *stringCache) Stats(options ...cache.Option) (cache.Stats, error) {
+	if err := c.lc.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	stats := c.stats.Stats()
+	stats.Entries, stats.Expired, stats.Bytes = -1, -1, -1
+	return stats, nil
 }
 
 func (c *stringCache) Codec() cache.Codec {
 	return c.codec
 }
 
-func (c *stringCache) keyString(key interface{}) string {
+func (c *stringCache) TTL(key interface{}, options ...cache.Option) (time.Duration, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return 0, err
+	}
+	ms, err := c.rdb.DoContext(o.Ctx, "PTTL", keyStr).Int64()
+	if err != nil {
+		return 0, cache.NewCacheError(err)
+	}
+	switch {
+	case ms == -2:
+		return 0, cache.ErrNotFound
+	case ms < 0:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (c *stringCache) Expire(key interface{}, ttl time.Duration, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	return c.pexpire(&o, keyStr, ttl)
+}
+
+func (c *stringCache) Persist(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	ok, err := c.rdb.DoContext(o.Ctx, "PERSIST", keyStr).Bool()
+	if err != nil {
+		return cache.NewCacheError(err)
+	}
+	if ok {
+		return nil
+	}
+	// not existed or no ttl
+	ok, err = c.rdb.DoContext(o.Ctx, "EXISTS", keyStr).Bool()
+	if err != nil {
+		return cache.NewCacheError(err)
+	}
+	if !ok {
+		return cache.ErrNotFound
+	}
+	return nil
+}
+
+// Touch renews the expiry of key with cache.WithTTL(), which is required as redis doesn't keep
+// the ttl a key was set with, cache.ErrUnsupported is returned without it.
+func (c *stringCache) Touch(key interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	ttl := o.HardTTL()
+	if ttl <= 0 {
+		return cache.ErrUnsupported
+	}
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	return c.pexpire(&o, keyStr, ttl)
+}
+
+func (c *stringCache) Incr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, 1, options...)
+}
+
+func (c *stringCache) Decr(key interface{}, options ...cache.Option) (int64, error) {
+	return c.IncrBy(key, -1, options...)
+}
+
+// IncrBy increments the value of key by INCRBY, the value is stored as a plain integer without codec.
+func (c *stringCache) IncrBy(key interface{}, delta int64, options ...cache.Option) (int64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return 0, err
+	}
+	v, err := incrScript.run(o.Ctx, c.rdb, []string{keyStr}, "INCRBY", delta, milliseconds(o.HardTTL())).Int64()
+	if err != nil {
+		return 0, counterError(err)
+	}
+	c.stats.AddSets(1)
+	return v, nil
+}
+
+// IncrByFloat increments the value of key by INCRBYFLOAT, the value is stored as a plain number without codec.
+func (c *stringCache) IncrByFloat(key interface{}, delta float64, options ...cache.Option) (float64, error) {
+	if err := c.lc.Err(); err != nil {
+		return 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return 0, err
+	}
+	v, err := incrScript.run(o.Ctx, c.rdb, []string{keyStr}, "INCRBYFLOAT", delta, milliseconds(o.HardTTL())).Float64()
+	if err != nil {
+		return 0, counterError(err)
+	}
+	c.stats.AddSets(1)
+	return v, nil
+}
+
+// Add sets value by SET NX.
+func (c *stringCache) Add(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	err := c.setIf(&o, key, value, "NX")
+	if err == redis.Nil {
+		return cache.ErrExisted
+	}
+	return err
+}
+
+// Replace sets value by SET XX.
+func (c *stringCache) Replace(key, value interface{}, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	err := c.setIf(&o, key, value, "XX")
+	if err == redis.Nil {
+		return cache.ErrNotFound
+	}
+	return err
+}
+
+// setIf sets value with the condition NX or XX, redis.Nil is returned if the condition isn't met.
+func (c *stringCache) setIf(o *cache.Options, key, value interface{}, cond string) error {
+	keyStr, err := c.keyString(o, key)
+	if err != nil {
+		return err
+	}
+	b, err := encodeValue(c.codec, value, o, c.Clock.Now())
+	if err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 3, 6)
+	args[0] = "SET"
+	args[1] = keyStr
+	args[2] = b
+	args = append(args, expireArgs(o.HardTTL())...)
+	args = append(args, cond)
+	if err := c.rdb.DoContext(o.Ctx, args...).Err(); err != nil {
+		if err == redis.Nil {
+			return err
+		}
+		return cache.NewCacheError(err)
+	}
+	c.stats.AddSets(1)
+	return nil
+}
+
+// GetWithVersion is like Get, a value without a version gets one in its envelope, which is renewed by
+// CompareAndSwap and dropped by other writes.
+func (c *stringCache) GetWithVersion(key interface{}, options ...cache.Option) (interface{}, uint64, error) {
+	if err := c.lc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	ret, err := getVersionScript.run(o.Ctx, c.rdb, []string{keyStr}, newVersion()).String()
+	if err != nil {
+		if err == redis.Nil {
+			c.stats.Hit(false)
+			return nil, 0, cache.ErrNotFound
+		}
+		return nil, 0, cache.NewCacheError(err)
+	}
+	c.stats.Hit(true)
+	e := decodeEnvelope([]byte(ret))
+	v, err := c.codec.Decode(e.value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, e.version, nil
+}
+
+// CompareAndSwap compares the version and sets value in a lua script.
+func (c *stringCache) CompareAndSwap(key, value interface{}, version uint64, options ...cache.Option) error {
+	if err := c.lc.Err(); err != nil {
+		return err
+	}
+
+	var o cache.Options
+	o.Apply(options...)
+
+	keyStr, err := c.keyString(&o, key)
+	if err != nil {
+		return err
+	}
+	b, err := encodeSwap(c.codec, value, &o, c.Clock.Now(), version)
+	if err != nil {
+		return err
+	}
+	ret, err := casScript.run(o.Ctx, c.rdb, []string{keyStr}, b, versionBytes(version), milliseconds(o.HardTTL())).Int64()
+	if err != nil {
+		return cache.NewCacheError(err)
+	}
+	if ret == 1 {
+		c.stats.AddSets(1)
+	}
+	return casResult(ret)
+}
+
+// pexpire sets the ttl of key, key is deleted if ttl is not positive.
+func (c *stringCache) pexpire(o *cache.Options, keyStr string, ttl time.Duration) error {
+	ok, err := c.rdb.DoContext(o.Ctx, "PEXPIRE", keyStr, milliseconds(ttl)).Bool()
+	if err != nil {
+		return cache.NewCacheError(err)
+	}
+	if !ok {
+		return cache.ErrNotFound
+	}
+	return nil
+}
+
+// keyString makes the redis key of key, under the current generation if Generation is enabled.
+func (c *stringCache) keyString(o *cache.Options, key interface{}) (string, error) {
+	prefix, err := c.keyPrefix(o)
+	if err != nil {
+		return "", err
+	}
 	keyStr := toString(key, c.codec)
-	if c.keyStringFunc != nil {
-		keyStr = c.keyStringFunc(keyStr)
+	if c.KeyStringFunc != nil {
+		keyStr = c.KeyStringFunc(keyStr)
 	}
-	return keyStr
+	return prefix + keyStr, nil
 }
@@ -1,6 +1,8 @@
 package redis
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -55,6 +57,44 @@ func Test_stringStoreGet(t *testing.T) {
 	}
 }
 
+func Test_stringStoreGetStale(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := NewStringCache(rdb, json.NewCodec(), func(key string) string {
+		return "test_stale_" + key
+	})
+
+	c.Set("stale", 1, cache.WithTTL(time.Second), cache.WithStaleTTL(time.Second))
+	c.Set("fresh", 2, cache.WithTTL(time.Minute))
+
+	for _, key := range []string{"stale", "fresh"} {
+		_, stale, err := c.GetStale(key)
+		assert.NoError(t, err)
+		assert.False(t, stale, key)
+	}
+
+	time.Sleep(1500 * time.Millisecond) // wait for soft expires
+
+	v, stale, err := c.GetStale("stale")
+	assert.NoError(t, err)
+	assert.True(t, stale)
+	var val int
+	assert.NoError(t, c.Codec().DecodeTo(v, &val))
+	assert.Equal(t, 1, val)
+
+	ret, err := c.MGet([]interface{}{"stale", "fresh"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ret))
+
+	time.Sleep(time.Second) // wait for hard expires
+
+	_, _, err = c.GetStale("stale")
+	assert.Equal(t, cache.ErrNotFound, err)
+}
+
 func Test_stringStoreSet(t *testing.T) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     "localhost:6379", // use default Addr
@@ -75,10 +115,59 @@ func Test_stringStoreSet(t *testing.T) {
 }
 
 func Test_stringStoreMGet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := NewStringCache(rdb, json.NewCodec(), func(key string) string {
+		return "test_mget_" + key
+	})
 
+	n := 10
+	keys := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		c.Delete(i)
+		if i%2 == 0 {
+			c.Set(i, i)
+		}
+		keys = append(keys, i)
+	}
+
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, n/2, len(ret))
+	for i := 0; i < n; i += 2 {
+		var val int
+		err = c.Codec().DecodeTo(ret[i], &val)
+		assert.NoError(t, err)
+		assert.Equal(t, i, val)
+	}
 }
 
 func Test_stringStoreMSet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379", // use default Addr
+		Password: "",               // no password set
+		DB:       0,                // use default DB
+	})
+	c := cache.NewTypedCache[int, string](NewStringCache(rdb, json.NewCodec(), func(key string) string {
+		return "test_mset_" + key
+	}))
+
+	n := 10
+	kvs := make(map[int]string)
+	keys := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		kvs[i] = fmt.Sprintf("value of %d", i)
+		keys = append(keys, i)
+	}
+	err := c.MSet(kvs, cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, kvs, ret)
 }
 
 func Test_stringStoreExists(t *testing.T) {
@@ -157,4 +246,269 @@ func Test_stringStoreClear(t *testing.T) {
 	c := NewStringCache(rdb, json.NewCodec(), nil)
 	err := c.Clear()
 	assert.Equal(t, cache.ErrUnsupported, err)
+
+	c = NewStringCacheWithConfig(rdb, json.NewCodec(), &StringCacheConfig{Prefix: "clear[1]:", ScanCount: 3})
+	other := NewStringCacheWithConfig(rdb, json.NewCodec(), &StringCacheConfig{Prefix: "clear1:"})
+	c.Clear()
+	n := 10
+	for i := 0; i < n; i++ {
+		c.Set(i, i)
+		other.Set(i, i)
+	}
+	assert.True(t, rdb.Exists("clear[1]:0").Val() == 1)
+	cnt, err := c.ClearCount()
+	assert.NoError(t, err)
+	assert.Equal(t, n, cnt)
+	for i := 0; i < n; i++ {
+		ok, _ := c.Exists(i)
+		assert.False(t, ok)
+		ok, _ = other.Exists(i)
+		assert.True(t, ok)
+	}
+	assert.NoError(t, other.Clear())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Set(0, 0)
+	_, err = c.ClearCount(cache.WithContext(ctx))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func Test_stringStoreExpirer(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewStringCache(rdb, json.NewCodec(), nil)
+	c.Delete("ttl")
+	c.Delete("no ttl")
+	c.Delete("missing")
+
+	c.Set("ttl", 1, cache.WithTTL(time.Hour))
+	c.Set("no ttl", 2)
+
+	ttl, err := c.TTL("ttl")
+	assert.NoError(t, err)
+	assert.True(t, ttl > time.Hour-time.Minute && ttl <= time.Hour)
+	ttl, err = c.TTL("no ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = c.TTL("missing")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	assert.NoError(t, c.Expire("ttl", time.Minute))
+	ttl, _ = c.TTL("ttl")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	// touch requires a ttl
+	assert.Equal(t, cache.ErrUnsupported, c.Touch("ttl"))
+	assert.NoError(t, c.Touch("ttl", cache.WithTTL(time.Hour)))
+	ttl, _ = c.TTL("ttl")
+	assert.True(t, ttl > time.Minute)
+	assert.Equal(t, cache.ErrNotFound, c.Touch("missing", cache.WithTTL(time.Hour)))
+
+	assert.NoError(t, c.Persist("ttl"))
+	assert.NoError(t, c.Persist("no ttl"))
+	assert.Equal(t, cache.ErrNotFound, c.Persist("missing"))
+	ttl, _ = c.TTL("ttl")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	assert.NoError(t, c.Expire("ttl", 0))
+	ok, _ := c.Exists("ttl")
+	assert.False(t, ok)
+	assert.Equal(t, cache.ErrNotFound, c.Expire("ttl", time.Second))
+}
+
+func Test_stringStoreCounter(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewStringCache(rdb, json.NewCodec(), nil)
+	c.Delete("cnt")
+	c.Delete("float")
+	c.Delete("str")
+
+	v, err := c.Incr("cnt", cache.WithTTL(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	v, err = c.IncrBy("cnt", 10, cache.WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), v)
+	v, err = c.Decr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), v)
+	ttl, _ := c.TTL("cnt")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	var got int
+	ret, err := c.Get("cnt")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Codec().DecodeTo(ret, &got))
+	assert.Equal(t, 10, got)
+
+	f, err := c.IncrByFloat("float", 1.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	ttl, _ = c.TTL("float")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	c.Set("str", "a")
+	_, err = c.Incr("str")
+	assert.Equal(t, cache.ErrTypeMismatch, err)
+
+	// a counter set with a ttl can be incremented
+	c.Set("cnt", 5, cache.WithTTL(time.Minute))
+	v, err = c.Incr("cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), v)
+}
+
+func Test_stringStoreConditionalSet(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewStringCache(rdb, json.NewCodec(), nil)
+	c.Delete("a")
+
+	assert.Equal(t, cache.ErrNotFound, c.Replace("a", 1))
+	assert.NoError(t, c.Add("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrExisted, c.Add("a", 2))
+	assert.NoError(t, c.Replace("a", 3))
+	ttl, _ := c.TTL("a")
+	assert.Equal(t, time.Duration(0), ttl)
+
+	var got int
+	v, ver, err := c.GetWithVersion("a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Codec().DecodeTo(v, &got))
+	assert.Equal(t, 3, got)
+	assert.NoError(t, c.CompareAndSwap("a", 4, ver, cache.WithTTL(time.Minute)))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+	ttl, _ = c.TTL("a")
+	assert.True(t, ttl > 0)
+
+	// the version is kept by reads and changed by writes, even of the same value
+	_, ver, _ = c.GetWithVersion("a")
+	_, again, _ := c.GetWithVersion("a")
+	assert.Equal(t, ver, again)
+	ttl, _ = c.TTL("a")
+	assert.True(t, ttl > 0)
+	c.Set("a", 4)
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+	_, ver, _ = c.GetWithVersion("a")
+	assert.NoError(t, c.CompareAndSwap("a", 5, ver))
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 5, ver))
+
+	_, ver, _ = c.GetWithVersion("a")
+	c.Delete("a")
+	assert.Equal(t, cache.ErrNotFound, c.CompareAndSwap("a", 5, ver))
+	c.Set("a", 5)
+	assert.Equal(t, cache.ErrVersionMismatch, c.CompareAndSwap("a", 6, ver))
+}
+
+func Test_stringStoreGeneration(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	clock := cache.NewFakeClock(time.Now())
+	cfg := StringCacheConfig{Prefix: "gen_test:", Generation: true, GenerationCacheTTL: time.Minute, Clock: clock}
+	c := NewStringCacheWithConfig(rdb, json.NewCodec(), &cfg)
+	other := NewStringCacheWithConfig(rdb, json.NewCodec(), &cfg)
+	assert.NoError(t, c.Clear())
+	gen := rdb.Get("gen_test:generation").Val()
+
+	assert.NoError(t, c.Set("a", 1, cache.WithTTL(time.Minute)))
+	assert.Equal(t, int64(1), rdb.Exists("gen_test:"+gen+":a").Val())
+	v, err := other.Get("a")
+	assert.NoError(t, err)
+	var val int
+	assert.NoError(t, other.Codec().DecodeTo(v, &val))
+	assert.Equal(t, 1, val)
+
+	// Clear takes effect at once on the cache, and after the cached generation expires on other ones
+	n, err := c.ClearCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	_, err = c.Get("a")
+	assert.Equal(t, cache.ErrNotFound, err)
+	_, err = other.Get("a")
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	_, err = other.Get("a")
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	// keys of the old generation are left to expire
+	assert.Equal(t, int64(1), rdb.Exists("gen_test:"+gen+":a").Val())
+	assert.True(t, rdb.PTTL("gen_test:"+gen+":a").Val() > 0)
+}
+
+func Test_generationFetched(t *testing.T) {
+	now := time.Now()
+	var g generation
+	_, fresh := g.get(now)
+	assert.False(t, fresh)
+	assert.Equal(t, int64(1), g.fetched(1, now.Add(time.Second)))
+
+	// a fetch started before the generation is incremented doesn't bring back the old one
+	g.val, g.expireAt = 2, now.Add(2*time.Second)
+	assert.Equal(t, int64(2), g.fetched(1, now.Add(time.Second)))
+	val, fresh := g.get(now.Add(time.Second))
+	assert.True(t, fresh)
+	assert.Equal(t, int64(2), val)
+	assert.Equal(t, int64(3), g.fetched(3, now.Add(3*time.Second)))
+}
+
+func Test_stringStoreScan(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewStringCacheWithConfig(rdb, json.NewCodec(), &StringCacheConfig{Prefix: "scan_test:"})
+	c.Clear()
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("user:%d", i), i)
+	}
+	c.Set("order:1", 1)
+
+	var keys []string
+	var cursor uint64
+	for {
+		ks, next, err := c.Scan(context.Background(), cursor, "user:*", 5)
+		assert.NoError(t, err)
+		keys = append(keys, ks...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Len(t, keys, 20)
+	assert.Contains(t, keys, "user:0")
+	assert.NoError(t, c.Clear())
+}
+
+func Test_stringStoreStats(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	c := NewStringCacheWithConfig(rdb, json.NewCodec(), &StringCacheConfig{Prefix: "stats_test:"})
+	c.Clear()
+
+	c.Set("a", 1)
+	c.MSet(map[interface{}]interface{}{"b": 2, "c": 3})
+	c.Get("a")
+	c.Get("x")
+	c.MGet([]interface{}{"a", "y"})
+	c.Delete("a")
+	c.Delete("a")
+	assert.NoError(t, c.Clear())
+
+	s, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{
+		Entries: -1,
+		Expired: -1,
+		Bytes:   -1,
+		Hits:    2,
+		Misses:  2,
+		Sets:    3,
+		Deletes: 3,
+	}, s)
 }
@@ -2,13 +2,16 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v7"
 	"github.com/ryanking8215/go-cache"
 )
 
@@ -26,14 +29,63 @@ func notRedisError(err error) bool {
 	return false
 }
 
-func timeUnixNanoToString(t time.Time) string {
-	return strconv.FormatInt(t.UnixNano(), 10)
+// counterError converts the error of counter commands, values not a number result in cache.ErrTypeMismatch.
+func counterError(err error) error {
+	if notRedisError(err) {
+		return err
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "not an integer") || strings.Contains(msg, "not a valid float") ||
+		strings.Contains(msg, "not a float") {
+		return cache.ErrTypeMismatch
+	}
+	return cache.NewCacheError(err)
 }
 
 func usePrecise(dur time.Duration) bool {
 	return dur < time.Second || dur%time.Second != 0
 }
 
+// expireArgs returns the expiration arguments of SET command, nil if ttl is 0.
+func expireArgs(ttl time.Duration) []interface{} {
+	if ttl <= 0 {
+		return nil
+	}
+	if usePrecise(ttl) {
+		return []interface{}{"PX", int64(ttl / time.Millisecond)}
+	}
+	return []interface{}{"EX", int64(ttl / time.Second)}
+}
+
+// hasHashTag reports whether key has a hash tag, which decides its cluster slot instead of the whole key.
+func hasHashTag(key string) bool {
+	i := strings.IndexByte(key, '{')
+	return i >= 0 && strings.IndexByte(key[i+1:], '}') > 0
+}
+
+// milliseconds converts a positive ttl to milliseconds, at least 1.
+func milliseconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+// casResult converts the result of lua scripts for conditional writes: 1 if set, 0 if the version mismatches,
+// -1 if the key is missing.
+func casResult(ret int64) error {
+	switch ret {
+	case 1:
+		return nil
+	case 0:
+		return cache.ErrVersionMismatch
+	}
+	return cache.ErrNotFound
+}
+
 // toString converts i to a string, encoder is as a fallback method if we can't handle it by default
 // Copy from [goframe](https://github.com/gogf/gf/), thanks for it.
 func toString(i interface{}, encoder cache.Encoder) string {
@@ -117,3 +169,90 @@ func toString(i interface{}, encoder cache.Encoder) string {
 		}
 	}
 }
+
+// forEachMaster calls fn on every master concurrently in cluster mode, or on the client itself.
+func forEachMaster(rdb redis.UniversalClient, fn func(client *redis.Client) error) error {
+	switch c := rdb.(type) {
+	case *redis.ClusterClient:
+		return c.ForEachMaster(fn)
+	case *redis.Ring:
+		return c.ForEachShard(fn)
+	case *redis.Client:
+		return fn(c)
+	}
+	return cache.ErrUnsupported
+}
+
+// unlinkMatch unlinks the keys matching pattern on client by SCAN, count at a time, and returns the number
+// of keys removed. Keys are unlinked one by one in a pipeline, since they may be in different cluster slots.
+func unlinkMatch(ctx context.Context, client *redis.Client, pattern string, count int) (int, error) {
+	client = client.WithContext(ctx)
+	total := 0
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		keys, next, err := client.Scan(cursor, pattern, int64(count)).Result()
+		if err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			cmds := make([]*redis.IntCmd, 0, len(keys))
+			for _, key := range keys {
+				cmds = append(cmds, pipe.Unlink(key))
+			}
+			if _, err := pipe.ExecContext(ctx); err != nil {
+				return total, err
+			}
+			for _, cmd := range cmds {
+				total += int(cmd.Val())
+			}
+		}
+		if next == 0 {
+			return total, nil
+		}
+		cursor = next
+	}
+}
+
+// escapeGlob escapes the special characters of glob-style patterns of SCAN MATCH.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// scanResult parses the reply of SCAN and HSCAN, the cursor and the keys.
+func scanResult(ret interface{}, err error) ([]string, uint64, error) {
+	if err != nil {
+		if notRedisError(err) {
+			return nil, 0, err
+		}
+		return nil, 0, cache.NewCacheError(err)
+	}
+	vals, ok := ret.([]interface{})
+	if !ok || len(vals) != 2 {
+		return nil, 0, cache.NewCacheError(errors.New("count not match"))
+	}
+	s, _ := vals[0].(string)
+	next, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, 0, cache.NewCacheError(err)
+	}
+	items, _ := vals[1].([]interface{})
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if key, ok := item.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, next, nil
+}
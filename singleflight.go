@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// call is an in-flight or completed group.do call
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// panicError is the error of a call whose fn panicked, it carries the panic value and the stack.
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("cache: loader panicked: %v\n\n%s", p.value, p.stack)
+}
+
+// group suppresses duplicate calls with the same key, concurrent callers of the same key wait
+// for the first one and share its result.
+// If fn panics, the first caller panics with the same value and the waiters get a panicError.
+type group struct {
+	mu sync.Mutex
+	m  map[interface{}]*call
+}
+
+func (g *group) do(key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	if e, ok := c.err.(*panicError); ok {
+		panic(e.value)
+	}
+	return c.val, c.err
+}
+
+// doAsync calls fn in a new goroutine unless a call of the same key is in-flight.
+// A panic of fn is recovered, so it doesn't crash the process.
+func (g *group) doAsync(key interface{}, fn func() (interface{}, error)) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	if _, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		return
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+}
+
+func (g *group) doCall(c *call, key interface{}, fn func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.val, c.err = nil, &panicError{value: r, stack: debug.Stack()}
+		}
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+}
@@ -0,0 +1,103 @@
+package cache
+
+import "sync/atomic"
+
+// Stats holds the size and the counters of a cache. Sizes are negative if the cache can't tell them.
+type Stats struct {
+	Entries int64 // entries stored, including the expired ones not reclaimed yet
+	Expired int64 // entries expired but not reclaimed yet
+	Bytes   int64 // estimated bytes of entries
+
+	Hits        uint64 // keys read and found
+	Misses      uint64 // keys read but missing or expired
+	Evictions   uint64 // entries evicted for capacity
+	Expirations uint64 // expired entries reclaimed by the cache
+	Sets        uint64 // entries written
+	Deletes     uint64 // entries deleted, including the ones removed by Clear
+	GCRuns      uint64 // runs of GC, sweeping or timing wheel ticks
+}
+
+// Add adds the sizes and counters of o to s, a size stays negative if either one is unknown.
+func (s *Stats) Add(o Stats) {
+	s.Entries = addSize(s.Entries, o.Entries)
+	s.Expired = addSize(s.Expired, o.Expired)
+	s.Bytes = addSize(s.Bytes, o.Bytes)
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+	s.Sets += o.Sets
+	s.Deletes += o.Deletes
+	s.GCRuns += o.GCRuns
+}
+
+func addSize(a, b int64) int64 {
+	if a < 0 || b < 0 {
+		return -1
+	}
+	return a + b
+}
+
+// StatsReporter is implemented by caches which can report their Stats.
+type StatsReporter interface {
+	// Stats returns the size and the counters of the cache.
+	// Option supports cache.WithContext()
+	Stats(options ...Option) (Stats, error)
+}
+
+// StatsCounter counts the operations of a cache atomically, the zero value is ready to use.
+type StatsCounter struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	sets        uint64
+	deletes     uint64
+	gcRuns      uint64
+}
+
+// Hit counts a read found if ok, or a miss otherwise.
+func (s *StatsCounter) Hit(ok bool) {
+	if ok {
+		atomic.AddUint64(&s.hits, 1)
+	} else {
+		atomic.AddUint64(&s.misses, 1)
+	}
+}
+
+func (s *StatsCounter) AddHits(n int)        { atomic.AddUint64(&s.hits, uint64(n)) }
+func (s *StatsCounter) AddMisses(n int)      { atomic.AddUint64(&s.misses, uint64(n)) }
+func (s *StatsCounter) AddEvictions(n int)   { atomic.AddUint64(&s.evictions, uint64(n)) }
+func (s *StatsCounter) AddExpirations(n int) { atomic.AddUint64(&s.expirations, uint64(n)) }
+func (s *StatsCounter) AddSets(n int)        { atomic.AddUint64(&s.sets, uint64(n)) }
+func (s *StatsCounter) AddDeletes(n int)     { atomic.AddUint64(&s.deletes, uint64(n)) }
+func (s *StatsCounter) AddGCRuns(n int)      { atomic.AddUint64(&s.gcRuns, uint64(n)) }
+
+// Stats returns the counters, sizes are left to the cache.
+func (s *StatsCounter) Stats() Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Evictions:   atomic.LoadUint64(&s.evictions),
+		Expirations: atomic.LoadUint64(&s.expirations),
+		Sets:        atomic.LoadUint64(&s.sets),
+		Deletes:     atomic.LoadUint64(&s.deletes),
+		GCRuns:      atomic.LoadUint64(&s.gcRuns),
+	}
+}
+
+// EstimateSize estimates the bytes of v: Size() of a Sizer, the length of a string or []byte,
+// 8 for other basic types, and 16 for the others, which is the size of an interface.
+func EstimateSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case Sizer:
+		return v.Size()
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		return 8
+	}
+	return 16
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sized struct{}
+
+func (sized) Size() int64 { return 100 }
+
+func Test_StatsAdd(t *testing.T) {
+	s := Stats{Entries: 1, Expired: 0, Bytes: 10, Hits: 1, GCRuns: 2}
+	s.Add(Stats{Entries: 2, Expired: -1, Bytes: 20, Hits: 3, Misses: 1})
+	assert.Equal(t, Stats{Entries: 3, Expired: -1, Bytes: 30, Hits: 4, Misses: 1, GCRuns: 2}, s)
+}
+
+func Test_StatsCounter(t *testing.T) {
+	var c StatsCounter
+	c.Hit(true)
+	c.Hit(false)
+	c.AddHits(2)
+	c.AddSets(3)
+	c.AddEvictions(1)
+	assert.Equal(t, Stats{Hits: 3, Misses: 1, Sets: 3, Evictions: 1}, c.Stats())
+}
+
+func Test_EstimateSize(t *testing.T) {
+	assert.Equal(t, int64(3), EstimateSize("abc"))
+	assert.Equal(t, int64(2), EstimateSize([]byte("ab")))
+	assert.Equal(t, int64(8), EstimateSize(1))
+	assert.Equal(t, int64(100), EstimateSize(sized{}))
+	assert.Equal(t, int64(16), EstimateSize(struct{}{}))
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var DefaultSweeperConfig = SweeperConfig{
+	Interval:   100 * time.Millisecond,
+	SampleSize: 20,
+	Threshold:  0.25,
+	TimeBudget: 25 * time.Millisecond,
+}
+
+type SweeperConfig struct {
+	// Interval is the time between sweep cycles.
+	Interval time.Duration
+	// SampleSize is the number of keys sampled each round.
+	SampleSize int
+	// Threshold is the fraction of expired keys in a sample above which the cycle goes on with another round.
+	Threshold float64
+	// TimeBudget bounds the time spent by a cycle, not bound if equal 0.
+	TimeBudget time.Duration
+}
+
+// SweeperStats holds the counters of a Sweeper.
+type SweeperStats struct {
+	Cycles  uint64 // cycles run
+	Rounds  uint64 // samples taken
+	Sampled uint64 // keys sampled
+	Removed uint64 // expired keys removed
+	Errors  uint64 // failed samples
+}
+
+// Sweeper removes expired keys in background adaptively, like the active expiration of redis.
+// Each cycle samples keys with ttl and removes the expired ones, and samples again while the expired
+// fraction is above Threshold, so the sweeping speeds up as expired keys pile up, within TimeBudget.
+// Caches run Sweep every Interval by their Clock.
+type Sweeper struct {
+	SweeperConfig
+	target Sweepable
+	stats  SweeperStats
+}
+
+func NewSweeper(target Sweepable, cfg SweeperConfig) *Sweeper {
+	if cfg.SampleSize <= 0 {
+		cfg.SampleSize = DefaultSweeperConfig.SampleSize
+	}
+	return &Sweeper{
+		SweeperConfig: cfg,
+		target:        target,
+	}
+}
+
+// Sweep runs one cycle.
+func (s *Sweeper) Sweep() {
+	atomic.AddUint64(&s.stats.Cycles, 1)
+	start := time.Now()
+	for {
+		sampled, removed, err := s.target.SweepSample(s.SampleSize)
+		atomic.AddUint64(&s.stats.Rounds, 1)
+		atomic.AddUint64(&s.stats.Sampled, uint64(sampled))
+		atomic.AddUint64(&s.stats.Removed, uint64(removed))
+		if err != nil {
+			atomic.AddUint64(&s.stats.Errors, 1)
+			return
+		}
+		if sampled == 0 || float64(removed)/float64(sampled) <= s.Threshold {
+			return
+		}
+		if s.TimeBudget > 0 && time.Since(start) >= s.TimeBudget {
+			return
+		}
+	}
+}
+
+// Stats returns the counters.
+func (s *Sweeper) Stats() SweeperStats {
+	return SweeperStats{
+		Cycles:  atomic.LoadUint64(&s.stats.Cycles),
+		Rounds:  atomic.LoadUint64(&s.stats.Rounds),
+		Sampled: atomic.LoadUint64(&s.stats.Sampled),
+		Removed: atomic.LoadUint64(&s.stats.Removed),
+		Errors:  atomic.LoadUint64(&s.stats.Errors),
+	}
+}
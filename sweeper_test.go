@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// expiredKeys is a Sweepable of which the first expired keys of all are expired.
+type expiredKeys struct {
+	all, expired int
+}
+
+func (k *expiredKeys) SweepSample(n int) (int, int, error) {
+	if k.all == 0 {
+		return 0, 0, nil
+	}
+	if n > k.all {
+		n = k.all
+	}
+	removed := n * k.expired / k.all
+	k.all -= removed
+	k.expired -= removed
+	return n, removed, nil
+}
+
+func Test_Sweeper(t *testing.T) {
+	k := &expiredKeys{all: 1000, expired: 900}
+	s := NewSweeper(k, SweeperConfig{SampleSize: 20, Threshold: 0.25})
+	s.Sweep()
+
+	// stops when the expired fraction of a sample drops to the threshold
+	assert.True(t, float64(k.expired)/float64(k.all) < 0.3)
+	stats := s.Stats()
+	assert.Equal(t, uint64(1), stats.Cycles)
+	assert.Equal(t, uint64(900-k.expired), stats.Removed)
+	assert.Equal(t, stats.Rounds*20, stats.Sampled)
+
+	// one round only when few keys are expired
+	s.Sweep()
+	assert.Equal(t, stats.Rounds+1, s.Stats().Rounds)
+
+	// time budget
+	k = &expiredKeys{all: 1 << 30, expired: 1 << 30}
+	s = NewSweeper(k, SweeperConfig{SampleSize: 1, Threshold: 0.25, TimeBudget: 10 * time.Millisecond})
+	start := time.Now()
+	s.Sweep()
+	assert.True(t, time.Since(start) < time.Second)
+	assert.True(t, k.expired > 0)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import "context"
+
+// TypedCache is a type-safe wrapper over Cache.
+// Values retrieved from the underlying cache are decoded by its codec if there is one,
+// otherwise they are asserted to V directly.
+type TypedCache[K comparable, V any] struct {
+	c Cache
+}
+
+// NewTypedCache wraps c into a TypedCache.
+func NewTypedCache[K comparable, V any](c Cache) *TypedCache[K, V] {
+	return &TypedCache[K, V]{c: c}
+}
+
+// Cache Retrieves the underlying cache.
+func (c *TypedCache[K, V]) Cache() Cache {
+	return c.c
+}
+
+// Close closes the underlying cache if it's a Closer.
+func (c *TypedCache[K, V]) Close(ctx context.Context) error {
+	return closeCache(ctx, c.c)
+}
+
+// Get Retrieves a value from cache with a specified key.
+// If key is not found, ErrNotFound will be returned.
+// If the value can't be converted to V, ErrTypeMismatch or a codec error will be returned.
+func (c *TypedCache[K, V]) Get(key K, options ...Option) (V, error) {
+	v, err := c.c.Get(key, options...)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return c.decode(v)
+}
+
+// Set Stores a value identified by a key into cache.
+func (c *TypedCache[K, V]) Set(key K, value V, options ...Option) error {
+	return c.c.Set(key, value, options...)
+}
+
+// MGet Retrieves multiple values from cache with the specified keys.
+// A map returned which holds the key and value pairs existed.
+func (c *TypedCache[K, V]) MGet(keys []K, options ...Option) (map[K]V, error) {
+	ks := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		ks = append(ks, key)
+	}
+	vals, err := c.c.MGet(ks, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[K]V, len(vals))
+	for k, v := range vals {
+		key, ok := k.(K)
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		val, err := c.decode(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[key] = val
+	}
+	return ret, nil
+}
+
+// MSet Stores multiple items in cache.
+func (c *TypedCache[K, V]) MSet(keyValues map[K]V, options ...Option) error {
+	kvs := make(map[interface{}]interface{}, len(keyValues))
+	for k, v := range keyValues {
+		kvs[k] = v
+	}
+	return c.c.MSet(kvs, options...)
+}
+
+// Exists Checks whether a specified key exists in the cache.
+func (c *TypedCache[K, V]) Exists(key K, options ...Option) (bool, error) {
+	return c.c.Exists(key, options...)
+}
+
+// Delete Deletes a value with the specified key from cache.
+func (c *TypedCache[K, V]) Delete(key K, options ...Option) error {
+	return c.c.Delete(key, options...)
+}
+
+// Clear Deletes all values from cache.
+func (c *TypedCache[K, V]) Clear(options ...Option) error {
+	return c.c.Clear(options...)
+}
+
+func (c *TypedCache[K, V]) decode(v interface{}) (V, error) {
+	var ret V
+	if codec := c.c.Codec(); codec != nil {
+		if err := codec.DecodeTo(v, &ret); err != nil {
+			return ret, err
+		}
+		return ret, nil
+	}
+
+	ret, ok := v.(V)
+	if !ok {
+		return ret, ErrTypeMismatch
+	}
+	return ret, nil
+}
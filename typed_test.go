@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ryanking8215/go-cache"
+	"github.com/ryanking8215/go-cache/local"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TypedCacheGet(t *testing.T) {
+	c := cache.NewTypedCache[int, string](local.NewLocalCache())
+	n := 10
+	for i := 0; i < n; i++ {
+		assert.NoError(t, c.Set(i, fmt.Sprintf("%d", i)))
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := c.Get(i)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d", i), v)
+	}
+
+	_, err := c.Get(n)
+	assert.Equal(t, cache.ErrNotFound, err)
+
+	// value stored by the untyped cache with another type
+	assert.NoError(t, c.Cache().Set(n, n))
+	_, err = c.Get(n)
+	assert.Equal(t, cache.ErrTypeMismatch, err)
+}
+
+func Test_TypedCacheMGet(t *testing.T) {
+	c := cache.NewTypedCache[string, int](local.NewLRUCache(10))
+	n := 10
+	kvs := make(map[string]int)
+	for i := 0; i < n/2; i++ {
+		kvs[fmt.Sprintf("%d", i)] = i
+	}
+	assert.NoError(t, c.MSet(kvs))
+
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, fmt.Sprintf("%d", i))
+	}
+	ret, err := c.MGet(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, kvs, ret)
+}
+
+func Test_TypedCacheClose(t *testing.T) {
+	c := cache.NewTypedCache[int, int](local.NewLRUCache(10))
+	assert.NoError(t, c.Close(context.Background()))
+	_, err := c.Get(1)
+	assert.Equal(t, cache.ErrClosed, err)
+
+	// closed through the loading cache
+	lc := local.NewLRUCache(10)
+	c = cache.NewTypedCache[int, int](cache.NewLoadingCache(lc, nil))
+	assert.NoError(t, c.Close(context.Background()))
+	_, err = lc.Get(1)
+	assert.Equal(t, cache.ErrClosed, err)
+}